@@ -0,0 +1,115 @@
+// File: cmd/bot.go
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"cfst-client/pkg/notifier"
+)
+
+const botHelpText = `Available commands:
+/run - start a full test now
+/status - show the current state and last results
+/best - list the best IPs for each IP version
+/pause - pause scheduled tests
+/resume - resume scheduled tests`
+
+// handleBotCommand 处理 Telegram 机器人命令，返回 HTML 格式的回复
+func handleBotCommand(command string, args []string) string {
+	switch command {
+	case "run":
+		switch err := state.startRun(); {
+		case errors.Is(err, errShuttingDown):
+			return "cfst-client is shutting down; no new tests will be started."
+		case err != nil:
+			return "A test is already in progress."
+		}
+		return "Test started."
+	case "status":
+		return botStatus()
+	case "best":
+		return botBest()
+	case "pause":
+		if !state.setPaused(true) {
+			return "Scheduled tests are already paused."
+		}
+		return "Scheduled tests paused. Use /resume to resume them."
+	case "resume":
+		if !state.setPaused(false) {
+			return "Scheduled tests are not paused."
+		}
+		return "Scheduled tests resumed."
+	case "start", "help":
+		return botHelpText
+	default:
+		return fmt.Sprintf("Unknown command /%s.\n\n%s", html.EscapeString(command), botHelpText)
+	}
+}
+
+// botStatus 格式化当前运行状态
+func botStatus() string {
+	st := state.Status()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>%s (%s)</b>\n", html.EscapeString(st.Device), html.EscapeString(st.Operator))
+	if st.RunStartedAt != nil {
+		fmt.Fprintf(&sb, "State: running since %s\n", st.RunStartedAt.Format("2006-01-02 15:04:05"))
+	} else {
+		sb.WriteString("State: idle\n")
+	}
+	switch {
+	case st.Paused:
+		sb.WriteString("Schedule: paused\n")
+	case st.NextRun != nil:
+		fmt.Fprintf(&sb, "Next run: %s\n", st.NextRun.Format("2006-01-02 15:04:05"))
+	default:
+		sb.WriteString("Schedule: none\n")
+	}
+	for _, p := range st.PendingRetries {
+		fmt.Fprintf(&sb, "Delayed retry IP%s at %s\n", p.Version, p.DueAt.Format("2006-01-02 15:04:05"))
+	}
+	for _, version := range []string{"v4", "v6"} {
+		res, ok := st.LastResults[version]
+		if !ok {
+			continue
+		}
+		if res.Success {
+			fmt.Fprintf(&sb, "IP%s: OK, %d results at %s\n", version, len(res.Results), res.FinishedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Fprintf(&sb, "IP%s: failed at %s: %s\n", version, res.FinishedAt.Format("2006-01-02 15:04:05"), html.EscapeString(res.Error))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// botBest 列出每个 IP 版本最近一次成功测试中的最优 IP，进程重启后从历史数据库读取
+func botBest() string {
+	cfg, _, _, hist := currentGlobals()
+	topN := 5
+	if cfg != nil {
+		topN = cfg.Notifications.TopN
+	}
+
+	st := state.Status()
+	var sections []string
+	for _, version := range []string{"v4", "v6"} {
+		res, ok := st.LastResults[version]
+		results := res.Results
+		if (!ok || !res.Success) && hist != nil {
+			if rec, err := hist.Latest(version); err == nil && rec != nil {
+				results = sortedResults(rec.Results)
+			}
+		}
+		if len(results) == 0 {
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("<b>IP%s</b>\n%s", version, html.EscapeString(notifier.FormatResults(results, topN))))
+	}
+	if len(sections) == 0 {
+		return "No results yet."
+	}
+	return strings.Join(sections, "\n\n")
+}
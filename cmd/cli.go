@@ -0,0 +1,254 @@
+// File: cmd/cli.go
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/history"
+)
+
+// 进程退出码
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitConfigError = 2
+)
+
+var (
+	errRunInProgress = errors.New("a test is already in progress")
+	errShuttingDown  = errors.New("shutting down")
+)
+
+const usageText = `Usage: cfst-client [global flags] <command> [flags]
+
+Commands:
+  run            Run as a daemon: test immediately, then on the cron schedule (default)
+  once           Run a single test and exit with a non-zero status on failure
+  validate       Check the configuration file and exit
+  update         Check for and install CloudflareSpeedTest updates, then exit
+  show-results   Print the latest results from the history database
+
+Global flags:
+  --config-dir   Configuration directory (env CFST_CONFIG_DIR, default /app/config)
+  --config       Configuration file (env CFST_CONFIG, default <config-dir>/config.yml)
+`
+
+// globalFlags 是所有子命令共用的参数
+type globalFlags struct {
+	configDir  string
+	configFile string
+}
+
+// register 将全局参数注册到 fs，默认值取自环境变量
+func (g *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.configDir, "config-dir", g.configDir, "configuration directory (env CFST_CONFIG_DIR)")
+	fs.StringVar(&g.configFile, "config", g.configFile, "configuration file (env CFST_CONFIG)")
+}
+
+// apply 根据参数设置全局配置路径
+func (g *globalFlags) apply() {
+	if g.configDir != "" {
+		configDir = g.configDir
+	}
+	configPath = filepath.Join(configDir, "config.yml")
+	if g.configFile != "" {
+		configPath = g.configFile
+	}
+}
+
+// runCLI 解析命令行并执行对应的子命令，返回进程退出码
+func runCLI(args []string) int {
+	g := &globalFlags{
+		configDir:  envOr("CFST_CONFIG_DIR", defaultConfigDir),
+		configFile: os.Getenv("CFST_CONFIG"),
+	}
+
+	root := flag.NewFlagSet("cfst-client", flag.ContinueOnError)
+	root.Usage = func() { fmt.Fprint(root.Output(), usageText) }
+	g.register(root)
+	if err := root.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitConfigError
+	}
+
+	command := "run"
+	rest := root.Args()
+	if len(rest) > 0 {
+		command, rest = rest[0], rest[1:]
+	}
+
+	fs := flag.NewFlagSet("cfst-client "+command, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usageText)
+		fmt.Fprintf(fs.Output(), "\nFlags for %s:\n", command)
+		fs.PrintDefaults()
+	}
+	g.register(fs)
+
+	var showVersion string
+	var showJSON bool
+	switch command {
+	case "run", "once", "validate", "update":
+	case "show-results":
+		fs.StringVar(&showVersion, "version", "", "only show results for this IP version (v4 or v6)")
+		fs.BoolVar(&showJSON, "json", false, "print results as JSON")
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usageText)
+		return exitConfigError
+	}
+	if err := fs.Parse(rest); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitConfigError
+	}
+	g.apply()
+
+	switch command {
+	case "once":
+		return cmdOnce()
+	case "validate":
+		return cmdValidate()
+	case "update":
+		return cmdUpdate()
+	case "show-results":
+		return cmdShowResults(showVersion, showJSON, os.Stdout)
+	default:
+		return runDaemon()
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// cmdOnce 执行一次完整测试后退出
+func cmdOnce() int {
+	daemonMode = false
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config %s: %v\n", configPath, err)
+		return exitConfigError
+	}
+	// [新增] 收到 SIGINT/SIGTERM 时终止正在运行的测速
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownCtx = ctx
+	applyConfig(cfg)
+	err = runAll(ctx)
+	if _, _, _, hist := currentGlobals(); hist != nil {
+		pruneHistory(hist)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Run failed: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// cmdValidate 检查配置文件
+func cmdValidate() int {
+	if _, err := config.Load(configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Config %s is invalid: %v\n", configPath, err)
+		return exitConfigError
+	}
+	fmt.Printf("Config %s is valid.\n", configPath)
+	return exitOK
+}
+
+// cmdUpdate 只执行 CloudflareSpeedTest 更新检查
+func cmdUpdate() int {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config %s: %v\n", configPath, err)
+		return exitConfigError
+	}
+	if err := checkUpdate(cfg, buildDispatcher(cfg)); err != nil {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// cmdShowResults 输出历史数据库中每个 IP 版本最近一次的结果
+func cmdShowResults(version string, asJSON bool, w io.Writer) int {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config %s: %v\n", configPath, err)
+		return exitConfigError
+	}
+	if !cfg.History.Enabled {
+		fmt.Fprintln(os.Stderr, "History is disabled in config.yml; no results to show.")
+		return exitFailure
+	}
+	// 只读打开，避免 --config-dir 写错时创建目录和空的历史文件
+	path := historyPath(cfg)
+	h, err := history.OpenReadOnly(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "No history at %s; no results recorded yet.\n", path)
+		return exitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read history: %v\n", err)
+		return exitFailure
+	}
+
+	versions := []string{"v4", "v6"}
+	if version != "" {
+		versions = []string{version}
+	}
+	var records []*history.Record
+	for _, v := range versions {
+		rec, err := h.Latest(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read history: %v\n", err)
+			return exitFailure
+		}
+		if rec != nil {
+			records = append(records, rec)
+		}
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(os.Stderr, "No results recorded yet.")
+		return exitFailure
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(records); err != nil {
+			return exitFailure
+		}
+		return exitOK
+	}
+	for _, rec := range records {
+		fmt.Fprintf(w, "IP%s  %s  %s (%s)  attempt %d  %d results\n", rec.Version, rec.Time.Format("2006-01-02 15:04:05"),
+			rec.Device, rec.Operator, rec.Attempt, len(rec.Results))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "IP\tLATENCY(ms)\tLOSS\tSPEED(MB/s)\tREGION")
+		for _, r := range sortedResults(rec.Results) {
+			fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\t%s\n", r.IP, r.LatencyMs, r.LossPct, r.DLMBps, r.Region)
+		}
+		tw.Flush()
+		fmt.Fprintln(w, strings.Repeat("-", 60))
+	}
+	return exitOK
+}
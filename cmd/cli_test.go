@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/history"
+	"cfst-client/pkg/models"
+)
+
+// newConfigDir 创建一个包含示例配置的配置目录
+func newConfigDir(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "config", "config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yml"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// runCLICapture 执行 runCLI 并返回退出码和标准输出、标准错误的内容，结束后恢复全局配置路径
+func runCLICapture(t *testing.T, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	oldDir, oldPath, oldOut, oldErr := configDir, configPath, os.Stdout, os.Stderr
+	outFile, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, os.Stderr = outFile, errFile
+	defer func() {
+		configDir, configPath, os.Stdout, os.Stderr = oldDir, oldPath, oldOut, oldErr
+		outFile.Close()
+		errFile.Close()
+	}()
+
+	code = runCLI(args)
+	out, _ := os.ReadFile(outFile.Name())
+	errOut, _ := os.ReadFile(errFile.Name())
+	return code, string(out), string(errOut)
+}
+
+func TestRunCLIConfigPrecedence(t *testing.T) {
+	envDir, flagDir := newConfigDir(t), newConfigDir(t)
+	envFile := filepath.Join(newConfigDir(t), "config.yml")
+	flagFile := filepath.Join(newConfigDir(t), "config.yml")
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		wantPath string
+	}{
+		{"env dir", map[string]string{"CFST_CONFIG_DIR": envDir}, []string{"validate"}, filepath.Join(envDir, "config.yml")},
+		{"flag dir overrides env dir", map[string]string{"CFST_CONFIG_DIR": envDir}, []string{"--config-dir", flagDir, "validate"}, filepath.Join(flagDir, "config.yml")},
+		{"flag after command", map[string]string{"CFST_CONFIG_DIR": envDir}, []string{"validate", "--config-dir", flagDir}, filepath.Join(flagDir, "config.yml")},
+		{"env file overrides dir", map[string]string{"CFST_CONFIG_DIR": envDir, "CFST_CONFIG": envFile}, []string{"validate"}, envFile},
+		{"flag file overrides env file", map[string]string{"CFST_CONFIG": envFile}, []string{"--config", flagFile, "validate"}, flagFile},
+		{"flag file overrides flag dir", nil, []string{"--config-dir", flagDir, "--config", flagFile, "validate"}, flagFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CFST_CONFIG_DIR", "")
+			t.Setenv("CFST_CONFIG", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			code, stdout, stderr := runCLICapture(t, tt.args...)
+			if code != exitOK {
+				t.Fatalf("exit code = %d, stderr %q", code, stderr)
+			}
+			if want := "Config " + tt.wantPath + " is valid.\n"; stdout != want {
+				t.Errorf("stdout = %q, want %q", stdout, want)
+			}
+		})
+	}
+}
+
+func TestRunCLIExitCodes(t *testing.T) {
+	dir := newConfigDir(t)
+	invalid := filepath.Join(t.TempDir(), "invalid.yml")
+	if err := os.WriteFile(invalid, []byte("device_name: d\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CFST_CONFIG_DIR", dir)
+	t.Setenv("CFST_CONFIG", "")
+
+	tests := []struct {
+		name       string
+		args       []string
+		want       int
+		wantStderr string
+	}{
+		{"help", []string{"-h"}, exitOK, "Usage:"},
+		{"command help", []string{"show-results", "-h"}, exitOK, "Flags for show-results"},
+		{"valid config", []string{"validate"}, exitOK, ""},
+		{"unknown command", []string{"frobnicate"}, exitConfigError, `unknown command "frobnicate"`},
+		{"unknown flag", []string{"validate", "--nope"}, exitConfigError, "flag provided but not defined"},
+		{"invalid config", []string{"--config", invalid, "validate"}, exitConfigError, "is invalid"},
+		{"missing config", []string{"--config", filepath.Join(dir, "missing.yml"), "show-results"}, exitConfigError, "Failed to load config"},
+		{"no history", []string{"show-results"}, exitFailure, "No history at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCLICapture(t, tt.args...)
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d (stderr %q)", code, tt.want, stderr)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestShowResultsDoesNotCreateHistory(t *testing.T) {
+	file := filepath.Join(newConfigDir(t), "config.yml")
+	wrongDir := filepath.Join(t.TempDir(), "typo")
+	code, _, stderr := runCLICapture(t, "--config", file, "--config-dir", wrongDir, "show-results")
+	if code != exitFailure || !strings.Contains(stderr, "No history at") {
+		t.Errorf("exit code = %d, stderr %q, want 1 and a no-history message", code, stderr)
+	}
+	if _, err := os.Stat(wrongDir); !os.IsNotExist(err) {
+		t.Errorf("show-results created %s (stat err %v)", wrongDir, err)
+	}
+}
+
+func TestShowResults(t *testing.T) {
+	dir := newConfigDir(t)
+	h, err := history.Open(filepath.Join(dir, "history.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local)
+	for _, rec := range []history.Record{
+		{Time: at.Add(-time.Hour), Version: "v4", Attempt: 1, Device: "dev", Operator: "op",
+			Results: []models.DeviceResult{{IP: "9.9.9.9", LatencyMs: 99}}},
+		{Time: at, Version: "v4", Attempt: 2, Device: "dev", Operator: "op", Results: []models.DeviceResult{
+			{IP: "2.2.2.2", LatencyMs: 20, DLMBps: 5, Region: "HKG"},
+			{IP: "1.1.1.1", LatencyMs: 10, DLMBps: 8.5, Region: "LAX"},
+		}},
+		{Time: at, Version: "v6", Attempt: 1, Device: "dev", Operator: "op",
+			Results: []models.DeviceResult{{IP: "2606:4700::1", LatencyMs: 30}}},
+	} {
+		if err := h.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, stdout, stderr := runCLICapture(t, "--config-dir", dir, "show-results", "--version", "v4")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr %q", code, stderr)
+	}
+	want := "IPv4  2024-05-01 08:30:00  dev (op)  attempt 2  2 results\n" +
+		"IP       LATENCY(ms)  LOSS  SPEED(MB/s)  REGION\n" +
+		"1.1.1.1  10           0.00  8.50         LAX\n" +
+		"2.2.2.2  20           0.00  5.00         HKG\n" +
+		strings.Repeat("-", 60) + "\n"
+	if stdout != want {
+		t.Errorf("text output =\n%s\nwant\n%s", stdout, want)
+	}
+
+	code, stdout, stderr = runCLICapture(t, "--config-dir", dir, "show-results", "--json")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr %q", code, stderr)
+	}
+	var records []history.Record
+	if err := json.Unmarshal([]byte(stdout), &records); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", stdout, err)
+	}
+	if len(records) != 2 || records[0].Version != "v4" || records[0].Attempt != 2 || records[1].Version != "v6" {
+		t.Errorf("JSON records = %+v, want the latest v4 and v6 records", records)
+	}
+}
@@ -3,148 +3,556 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"cfst-client/pkg/api"
 	"cfst-client/pkg/config"
-	"cfst-client/pkg/gist"
+	"cfst-client/pkg/dns"
+	"cfst-client/pkg/history"
 	"cfst-client/pkg/installer"
+	"cfst-client/pkg/metrics"
 	"cfst-client/pkg/models"
 	"cfst-client/pkg/notifier"
+	"cfst-client/pkg/storage"
 	"cfst-client/pkg/tester"
-	"github.com/robfig/cron/v3"
 )
 
-const configDir = "/app/config"
+// defaultConfigDir 是 Docker 镜像中的默认配置目录，可通过命令行参数或环境变量覆盖
+const defaultConfigDir = "/app/config"
 
 var (
 	runLock    sync.Mutex
-	configPath = filepath.Join(configDir, "config.yml")
+	configDir  = defaultConfigDir
+	configPath = filepath.Join(defaultConfigDir, "config.yml")
+	// daemonMode 为 false 时（once 子命令）不安排延迟重试，因为进程会在测试后退出
+	daemonMode = true
+	// [新增] 取消时正在运行的测试会随之终止；常驻模式下在退出宽限期结束后取消
+	shutdownCtx = context.Background()
+	// [新增] 收到退出信号后置为 true，之后不再启动新的测试
+	shuttingDown atomic.Bool
+	// [新增] 测试结束后仍在后台运行的任务（如延迟重试通知），退出前需要等待
+	background sync.WaitGroup
 )
 
-// [新增] 全局变量，以便延迟任务可以访问它们
+// [新增] 全局变量，以便延迟任务可以访问它们，读写时需持有 globalsMu
 var (
-	globalGistClient *gist.Client
-	globalNotifiers  []notifier.Notifier
+	globalStorages   []storage.Storage
+	globalDispatcher *notifier.Dispatcher
+	globalHistory    *history.Store
 )
 
 func main() {
-	// 立即执行一次测试
-	go runAllTests()
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// runDaemon 启动常驻模式：立即执行一次测试，然后按 cron 表达式定时执行
+func runDaemon() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// [修改] 测试使用独立的 context，收到信号后先给当前测试一段宽限期
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	defer cancelRuns()
+	shutdownCtx = runCtx
 
+	raw, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Printf("Failed to read initial config: %v. Please check the config file.", err)
+		return exitConfigError
+	}
 	cfg, err := config.Load(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load initial config: %v. Please check the config file.", err)
+		log.Printf("Failed to load initial config: %v. Please check the config file.", err)
+		return exitConfigError
 	}
+	applyConfig(cfg)
 
-	if cfg.Cron != "" {
-		log.Printf("Scheduling tests with cron expression: %s", cfg.Cron)
-		c := cron.New()
-		_, err := c.AddFunc(cfg.Cron, runAllTests)
+	// [新增] 恢复上次退出时保存的延迟重试
+	restorePendingRetries()
+	go pruneHistoryLoop(ctx)
+
+	// 立即执行一次测试
+	go runAllTests(runCtx)
+
+	// [新增] 启动内置 HTTP 状态接口和 Prometheus 指标接口
+	var server *api.Server
+	var servers []httpServer // 退出时需要关闭的 HTTP 服务
+	if cfg.API.Enabled {
+		server = api.NewServer(state, cfg.API.Token)
+		servers = append(servers, server)
+	}
+	if cfg.Metrics.Enabled {
+		if server != nil && cfg.Metrics.Listen == cfg.API.Listen {
+			// 共用监听地址时 /metrics 与 API 使用相同的认证
+			server.Handle("GET /metrics", metrics.Default.Handler())
+		} else {
+			mux := http.NewServeMux()
+			mux.Handle("GET /metrics", metrics.Default.Handler())
+			metricsServer := &http.Server{
+				Addr:              cfg.Metrics.Listen,
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			servers = append(servers, metricsServer)
+			go func() {
+				log.Printf("Metrics server listening on %s", cfg.Metrics.Listen)
+				// 指标接口不可用时只记录错误，测速和上传照常进行
+				if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Printf("ERROR: Metrics server failed, continuing without /metrics: %v", err)
+				}
+			}()
+		}
+	}
+	if server != nil {
+		go func() {
+			if err := server.ListenAndServe(cfg.API.Listen); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("ERROR: API server failed, continuing without the HTTP API: %v", err)
+			}
+		}()
+	}
+
+	// [新增] 启动 Telegram 机器人命令
+	if tg := cfg.Notifications.Telegram; tg.Commands && tg.BotToken != "" && tg.ChatID != "" {
+		bot, err := notifier.NewTelegramBot(tg, handleBotCommand)
 		if err != nil {
-			log.Fatalf("Error adding cron job: %v", err)
+			log.Printf("WARN: Failed to initialize Telegram bot: %v", err)
+		} else {
+			go bot.Run(ctx)
 		}
-		c.Start()
-		select {}
 	}
+
+	if cfg.Cron != "" {
+		log.Printf("Scheduling tests with cron expression: %s", cfg.Cron)
+	}
+	if err := state.reschedule(cfg.Cron, scheduledRun); err != nil {
+		log.Printf("Error adding cron job: %v", err)
+		return exitConfigError
+	}
+
+	// [新增] 监听 config.yml 的变化并热加载，直到收到退出信号
+	watchConfig(ctx, raw)
+	stop() // 再次收到信号时直接退出
+
+	shutdown(cancelRuns, servers...)
+	return exitOK
 }
 
-func runAllTests() {
+// scheduledRun 是 cron 使用的入口，定时任务暂停时跳过本次执行
+func scheduledRun() {
+	if state.isPaused() {
+		log.Println("Scheduled tests are paused. Skipping this run.")
+		return
+	}
+	runAllTests(shutdownCtx)
+}
+
+// runAllTests 是 cron 和 API 使用的入口，忽略执行结果
+func runAllTests(ctx context.Context) {
+	_ = runAll(ctx)
+}
+
+// runAll 执行一次完整测试，任一 IP 版本失败时返回错误。
+// 整轮测试受 run_timeout_minutes 限制，ctx 取消时正在运行的测速会被终止。
+func runAll(ctx context.Context) error {
 	if !runLock.TryLock() {
 		log.Println("A test is already in progress. Skipping this run.")
-		return
+		return errRunInProgress
 	}
 	defer runLock.Unlock()
+	return runAllLocked(ctx)
+}
 
-	cfg, err := config.Load(configPath)
-	if err != nil {
-		log.Printf("ERROR: Failed to reload config: %v. Skipping this run.", err)
-		return
+// runAllLocked 是 runAll 的主体，调用方必须已持有 runLock
+func runAllLocked(ctx context.Context) error {
+	if shuttingDown.Load() {
+		log.Println("Shutting down. Skipping this run.")
+		return errShuttingDown
 	}
+	state.setRunning(true)
+	defer state.setRunning(false)
+
+	// [修改] 使用当前生效的配置；配置只由启动时加载和热加载（reloadConfig）替换，
+	// 以保证 cron 调度始终与生效的配置一致
+	cfg, sinks, dispatcher, _ := currentGlobals()
+	if cfg == nil {
+		log.Println("ERROR: No config has been loaded. Skipping this run.")
+		return errors.New("no config loaded")
+	}
+
+	ctx, cancel := withTimeoutMinutes(ctx, cfg.TestOptions.RunTimeoutMinutes)
+	defer cancel()
 
 	log.Println("--- Starting all tests with latest configuration ---")
+	metrics.Runs.Inc()
+
+	if cfg.Update.Check {
+		_ = checkUpdate(cfg, dispatcher)
+	} else {
+		log.Println("CloudflareSpeedTest update check is disabled in config.yml.")
+	}
 
-	if cfg.DeviceName == "" || cfg.LineOperator == "" {
-		log.Println("ERROR: 'device_name' and 'line_operator' in config.yml must not be empty. Skipping this run.")
+	if len(sinks) == 0 {
+		log.Println("ERROR: No usable storage target is configured. Skipping this run.")
+		return fmt.Errorf("no usable storage target is configured")
+	}
+
+	var failed []string
+	log.Println("--- Starting test for IPv4 ---")
+	if !runTest(ctx, sinks, cfg, "v4", dispatcher) {
+		failed = append(failed, "v4")
+	}
+
+	if cfg.TestIPv6 && ctx.Err() != nil {
+		log.Printf("Skipping IPv6 test: %v", ctx.Err())
+		failed = append(failed, "v6")
+	} else if cfg.TestIPv6 {
+		log.Println("--- Starting test for IPv6 ---")
+		if !runTest(ctx, sinks, cfg, "v6", dispatcher) {
+			failed = append(failed, "v6")
+		}
+	} else {
+		log.Println("IPv6 test is disabled in config.yml, skipping.")
+	}
+
+	log.Println("--- All tests done ---")
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("run stopped: %w", err)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("tests failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// [新增] 检查并安装 CloudflareSpeedTest 更新，并发送更新结果通知
+func checkUpdate(cfg *config.Config, dispatcher *notifier.Dispatcher) error {
+	log.Println("--- Checking for CloudflareSpeedTest updates ---")
+	res, err := installer.NewInstaller(cfg.ProxyPrefix, cfg.Update, cfg.Cf.Binary, configDir).InstallOrUpdate()
+	if err != nil {
+		log.Printf("WARN: Failed to update CloudflareSpeedTest: %v", err)
+		dispatcher.Dispatch(notifier.Event{
+			Type:     notifier.EventUpdate,
+			Title:    "CloudflareSpeedTest update failed",
+			Message:  fmt.Sprintf("Device %s (%s) failed to update CloudflareSpeedTest: %v", cfg.DeviceName, cfg.LineOperator, err),
+			Device:   cfg.DeviceName,
+			Operator: cfg.LineOperator,
+		})
+		return err
+	}
+	if res.Updated {
+		dispatcher.Dispatch(notifier.Event{
+			Type:     notifier.EventUpdate,
+			Title:    "CloudflareSpeedTest updated",
+			Message:  fmt.Sprintf("Device %s (%s) installed CloudflareSpeedTest %s (sha256 %s).", cfg.DeviceName, cfg.LineOperator, res.Version, res.SHA256),
+			Device:   cfg.DeviceName,
+			Operator: cfg.LineOperator,
+		})
+	}
+	log.Println("--- Update check finished ---")
+	return nil
+}
+
+// [新增] 根据 CloudflareSpeedTest 的执行结果确认刚安装的更新，
+// 程序无法启动或被信号终止（tester.ErrExec）时回滚到备份的旧版本；
+// 普通的非零退出码说明程序可以执行，仍会确认更新
+func confirmOrRollbackUpdate(cfg *config.Config, binPath string, runErr error, dispatcher *notifier.Dispatcher) {
+	if errors.Is(runErr, context.Canceled) || errors.Is(runErr, context.DeadlineExceeded) {
+		return // 被超时或退出信号终止时无法判断新程序是否可用，保留待确认标记
+	}
+	if !errors.Is(runErr, tester.ErrExec) {
+		installer.ConfirmPending(binPath)
 		return
 	}
+	rolledBack, err := installer.RollbackPending(binPath)
+	if err != nil {
+		log.Printf("ERROR: Failed to roll back CloudflareSpeedTest: %v", err)
+		return
+	}
+	if rolledBack {
+		dispatcher.Dispatch(notifier.Event{
+			Type:     notifier.EventUpdate,
+			Title:    "CloudflareSpeedTest update rolled back",
+			Message:  fmt.Sprintf("Device %s (%s) could not run the updated CloudflareSpeedTest (%v) and restored the previous version.", cfg.DeviceName, cfg.LineOperator, runErr),
+			Device:   cfg.DeviceName,
+			Operator: cfg.LineOperator,
+		})
+	}
+}
 
-	// [修改] 初始化全局通知器列表
-	globalNotifiers = nil
-	if cfg.Notifications.Enabled {
-		if cfg.Notifications.PushPlus.Token != "" {
-			globalNotifiers = append(globalNotifiers, &notifier.PushPlusNotifier{Token: cfg.Notifications.PushPlus.Token})
+// [新增] 根据配置构建通知器列表并包装为事件分发器
+func buildDispatcher(cfg *config.Config) *notifier.Dispatcher {
+	var notifiers []notifier.Notifier
+	nc := cfg.Notifications
+	if nc.Enabled {
+		if nc.PushPlus.Token != "" {
+			ppNotifier, err := notifier.NewPushPlusNotifier(nc.PushPlus)
+			if err != nil {
+				log.Printf("WARN: Failed to initialize PushPlus notifier: %v", err)
+			} else {
+				notifiers = append(notifiers, ppNotifier)
+			}
 		}
-		if cfg.Notifications.Telegram.BotToken != "" && cfg.Notifications.Telegram.ChatID != "" {
-			tgNotifier, err := notifier.NewTelegramNotifier(cfg.Notifications.Telegram)
+		if nc.Telegram.BotToken != "" && nc.Telegram.ChatID != "" {
+			tgNotifier, err := notifier.NewTelegramNotifier(nc.Telegram)
 			if err != nil {
 				log.Printf("WARN: Failed to initialize Telegram notifier: %v", err)
 			} else {
-				globalNotifiers = append(globalNotifiers, tgNotifier)
+				notifiers = append(notifiers, tgNotifier)
 			}
 		}
+		if nc.Bark.DeviceKey != "" {
+			notifiers = append(notifiers, notifier.NewBarkNotifier(nc.Bark))
+		}
+		if nc.ServerChan.SendKey != "" {
+			notifiers = append(notifiers, notifier.NewServerChanNotifier(nc.ServerChan))
+		}
+		if nc.DingTalk.AccessToken != "" {
+			notifiers = append(notifiers, notifier.NewDingTalkNotifier(nc.DingTalk))
+		}
+		if nc.WeCom.Key != "" {
+			notifiers = append(notifiers, notifier.NewWeComNotifier(nc.WeCom))
+		}
+		if nc.Feishu.Token != "" {
+			notifiers = append(notifiers, notifier.NewFeishuNotifier(nc.Feishu))
+		}
+		if nc.SMTP.Host != "" {
+			smtpNotifier, err := notifier.NewSMTPNotifier(nc.SMTP)
+			if err != nil {
+				log.Printf("WARN: Failed to initialize email notifier: %v", err)
+			} else {
+				notifiers = append(notifiers, smtpNotifier)
+			}
+		}
+		for _, wc := range nc.Webhooks {
+			whNotifier, err := notifier.NewWebhookNotifier(wc)
+			if err != nil {
+				log.Printf("WARN: Failed to initialize webhook notifier %s: %v", wc.Name, err)
+				continue
+			}
+			// 重试在后台进行，退出时（测试被终止后）不再重试
+			whNotifier.SetContext(shutdownCtx)
+			notifiers = append(notifiers, whNotifier)
+		}
 	}
+	return notifier.NewDispatcher(nc.Events, notifiers...)
+}
 
-	if cfg.Update.Check {
-		log.Println("--- Checking for CloudflareSpeedTest updates ---")
-		err := installer.NewInstaller(cfg.ProxyPrefix, cfg.Update.ApiURL, cfg.Cf.Binary, configDir).InstallOrUpdate()
+// [新增] 根据配置构建结果存储目标，无法初始化的目标会被跳过
+func buildStorages(cfg *config.Config) []storage.Storage {
+	var sinks []storage.Storage
+	for _, sc := range cfg.Storage {
+		s, err := storage.New(sc, cfg, configDir)
 		if err != nil {
-			log.Printf("WARN: Failed to update CloudflareSpeedTest: %v", err)
-		} else {
-			log.Println("--- Update check finished ---")
+			log.Printf("WARN: Failed to initialize %s storage: %v", sc.Type, err)
+			continue
 		}
-	} else {
-		log.Println("CloudflareSpeedTest update check is disabled in config.yml.")
+		sinks = append(sinks, s)
 	}
+	return sinks
+}
 
-	// [修改] 初始化全局 Gist 客户端
-	globalGistClient = gist.NewClient(os.ExpandEnv(cfg.Gist.Token), cfg.ProxyPrefix)
+// sortedResults 返回按丢包率、延迟升序，下载速度降序排列的结果副本，不修改传入的切片
+func sortedResults(in []models.DeviceResult) []models.DeviceResult {
+	results := append([]models.DeviceResult(nil), in...)
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].LossPct != results[j].LossPct {
+			return results[i].LossPct < results[j].LossPct
+		}
+		// [修改] 使用未取整的平均延迟，避免同一毫秒内的结果无法区分
+		if li, lj := results[i].Latency(), results[j].Latency(); li != lj {
+			return li < lj
+		}
+		return results[i].DLMBps > results[j].DLMBps
+	})
+	return results
+}
 
-	log.Println("--- Starting test for IPv4 ---")
-	runTest(globalGistClient, cfg, "v4", globalNotifiers)
+// [新增] 根据配置打开历史数据库，未启用或打开失败时返回 nil
+func openHistory(cfg *config.Config) *history.Store {
+	if !cfg.History.Enabled {
+		return nil
+	}
+	h, err := history.Open(historyPath(cfg), historyRetention(cfg), cfg.History.MaxRecords)
+	if err != nil {
+		log.Printf("WARN: Failed to open history database: %v", err)
+		return nil
+	}
+	return h
+}
 
-	if cfg.TestIPv6 {
-		log.Println("--- Starting test for IPv6 ---")
-		runTest(globalGistClient, cfg, "v6", globalNotifiers)
-	} else {
-		log.Println("IPv6 test is disabled in config.yml, skipping.")
+// historyPath 返回历史数据库文件的路径，相对路径基于配置目录
+func historyPath(cfg *config.Config) string {
+	if filepath.IsAbs(cfg.History.File) {
+		return cfg.History.File
 	}
+	return filepath.Join(configDir, cfg.History.File)
+}
 
-	log.Println("--- All tests done ---")
+// historyRetention 返回历史记录的保留时长，0 表示不按时间清理
+func historyRetention(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.History.RetentionDays) * 24 * time.Hour
 }
 
-// [新增] 用于执行延迟重试的函数
-func scheduleDelayedRetry(version string) {
-	// 重新加载最新的配置，以防用户在等待期间修改了配置
-	cfg, err := config.Load(configPath)
+// pruneHistory 按保留策略清理历史数据库
+func pruneHistory(hist *history.Store) {
+	if n, err := hist.Prune(); err != nil {
+		log.Printf("WARN: Failed to prune history: %v", err)
+	} else if n > 0 {
+		log.Printf("Pruned %d expired history records.", n)
+	}
+}
+
+// historyPruneInterval 是常驻模式下清理历史数据库的间隔
+const historyPruneInterval = time.Hour
+
+// [修改] 常驻模式下定期清理历史数据库，而不是在每轮测试后重写文件。
+// 启动时先清理一次，之后每隔 historyPruneInterval 清理当前生效的数据库，ctx 取消时返回。
+func pruneHistoryLoop(ctx context.Context) {
+	ticker := time.NewTicker(historyPruneInterval)
+	defer ticker.Stop()
+	for {
+		if _, _, _, hist := currentGlobals(); hist != nil {
+			pruneHistory(hist)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// [新增] 使用已排序的结果更新 DNS 记录，失败只记录日志，不影响结果上传
+func updateDNS(cfg *config.Config, version string, results []models.DeviceResult) {
+	updater, err := dns.NewUpdater(cfg.DNS)
 	if err != nil {
-		log.Printf("DELAYED RETRY [IP%s]: ERROR: Failed to reload config: %v. Aborting delayed retry.", version, err)
+		log.Printf("WARN: Failed to initialize DNS updater: %v", err)
 		return
 	}
+	changed, err := updater.Update(version, results)
+	if err != nil {
+		log.Printf("WARN: Failed to update DNS records for IP%s: %v", version, err)
+		return
+	}
+	if changed {
+		log.Printf("DNS records for IP%s updated.", version)
+	}
+}
+
+// [新增] 用于执行延迟重试的函数
+func scheduleDelayedRetry(version string) {
+	// 使用当前生效的配置，配置热加载后新的设置会在重试时生效
+	cfg, _, dispatcher, _ := currentGlobals()
 
 	delay := time.Duration(cfg.TestOptions.DelayedRetry.DelayMinutes) * time.Minute
 	log.Printf("DELAYED RETRY [IP%s]: Test failed. Scheduling a delayed retry in %v.", version, delay)
+	dueAt := time.Now().Add(delay)
+	dispatcher.Dispatch(notifier.Event{
+		Type:      notifier.EventDelayedRetry,
+		Title:     fmt.Sprintf("IP%s delayed retry scheduled", version),
+		Message:   fmt.Sprintf("Device %s (%s) will retry the IP%s test in %v (at %s).", cfg.DeviceName, cfg.LineOperator, version, delay, dueAt.Format("2006-01-02 15:04:05")),
+		Device:    cfg.DeviceName,
+		Operator:  cfg.LineOperator,
+		IPVersion: version,
+	})
 
-	time.AfterFunc(delay, func() {
+	armDelayedRetry(version, dueAt)
+}
+
+// armDelayedRetry 登记延迟重试并在 dueAt 时执行单个 IP 版本的测试
+func armDelayedRetry(version string, dueAt time.Time) {
+	id := state.addPending(version, dueAt)
+	timer := time.AfterFunc(time.Until(dueAt), func() {
+		if shuttingDown.Load() {
+			// 保留登记，退出时会被保存
+			log.Printf("DELAYED RETRY [IP%s]: Shutting down. Skipping delayed retry.", version)
+			return
+		}
+		state.removePending(id)
 		log.Printf("DELAYED RETRY [IP%s]: Starting delayed retry now.", version)
 		if !runLock.TryLock() {
 			log.Printf("DELAYED RETRY [IP%s]: Another test is already in progress. Skipping delayed retry.", version)
 			return
 		}
 		defer runLock.Unlock()
+		state.setRunning(true)
+		defer state.setRunning(false)
 
 		// 使用最新的配置和全局客户端/通知器执行单次测试
-		runTest(globalGistClient, cfg, version, globalNotifiers)
+		cfg, sinks, dispatcher, _ := currentGlobals()
+		ctx, cancel := withTimeoutMinutes(shutdownCtx, cfg.TestOptions.RunTimeoutMinutes)
+		defer cancel()
+		runTest(ctx, sinks, cfg, version, dispatcher)
 	})
+	state.attachTimer(id, timer)
 }
 
-func runTest(gc *gist.Client, cfg *config.Config, version string, notifiers []notifier.Notifier) {
+// progressReporter 返回处理测速进度的回调：更新 API 状态，并在每个阶段开始、
+// 每完成 25% 时记录日志，结果表中的每个 IP 逐条记录
+func progressReporter(version string) tester.ProgressFunc {
+	lastPhase, lastStep := "", -1
+	return func(ev tester.ProgressEvent) {
+		if ev.Phase == tester.PhaseResult {
+			r := ev.Result
+			log.Printf("PROGRESS [IP%s]: %s %d/%d received, %.2f ms, %.2f MB/s %s", version, r.IP, r.Received, r.Sent, r.AvgLatencyMs, r.DLMBps, r.Region)
+			return
+		}
+		state.setProgress(version, api.Progress{
+			Phase:     ev.Phase,
+			Done:      ev.Done,
+			Total:     ev.Total,
+			Available: ev.Available,
+			UpdatedAt: ev.Time,
+		})
+		if ev.Total <= 0 {
+			return
+		}
+		step := ev.Done * 4 / ev.Total
+		if ev.Phase == lastPhase && step == lastStep {
+			return
+		}
+		lastPhase, lastStep = ev.Phase, step
+		if ev.Phase == tester.PhaseLatency {
+			log.Printf("PROGRESS [IP%s]: latency test %d/%d (%d available)", version, ev.Done, ev.Total, ev.Available)
+		} else {
+			log.Printf("PROGRESS [IP%s]: %s test %d/%d", version, ev.Phase, ev.Done, ev.Total)
+		}
+	}
+}
+
+// withTimeoutMinutes 为 ctx 加上以分钟为单位的超时，minutes 不大于 0 时不限时
+func withTimeoutMinutes(ctx context.Context, minutes int) (context.Context, context.CancelFunc) {
+	if minutes <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(minutes)*time.Minute)
+}
+
+// runAttempt 执行一次测速尝试并记录耗时，与测速引擎无关。
+// 每次尝试单独限时，超时只终止本次尝试。
+func runAttempt(ctx context.Context, cf tester.Tester, timeoutMinutes int) ([]models.DeviceResult, error) {
+	attemptCtx, cancel := withTimeoutMinutes(ctx, timeoutMinutes)
+	defer cancel()
+	start := time.Now()
+	results, err := cf.Run(attemptCtx)
+	metrics.CfstDuration.Observe(time.Since(start).Seconds())
+	return results, err
+}
+
+// runTest 执行单个 IP 版本的测试，结果至少成功上传到一个目标时返回 true
+func runTest(ctx context.Context, sinks []storage.Storage, cfg *config.Config, version string, dispatcher *notifier.Dispatcher) bool {
 	var testConfig config.CfConfig
 	var ipFile string
 	var baseGistFilename string
@@ -164,18 +572,48 @@ func runTest(gc *gist.Client, cfg *config.Config, version string, notifiers []no
 	finalArgs := append(testConfig.Args, "-f", ipFile)
 	localCsvPath := filepath.Join(configDir, testConfig.OutputFile)
 
-	cf := tester.NewCFSpeedTester(testConfig.Binary, localCsvPath, cfg.DeviceName, cfg.LineOperator, finalArgs)
+	// [新增] 根据配置选择测速引擎
+	var cf tester.Tester
+	switch testConfig.Engine {
+	case "native":
+		log.Printf("Using native Go speed-test engine for IP%s.", version)
+		cf = tester.NewNativeTester(testConfig.Native, ipFile, cfg.DeviceName, cfg.LineOperator)
+	default:
+		cfs := tester.NewCFSpeedTester(testConfig.Binary, localCsvPath, cfg.DeviceName, cfg.LineOperator, finalArgs)
+		// [新增] 解析实时进度并保存原始输出
+		cfs.SetProgress(progressReporter(version))
+		cfs.SetRunLog(filepath.Join(configDir, "logs"), "cfst-"+version)
+		cf = cfs
+	}
 
 	var finalResults []models.DeviceResult
 	for i := 0; i < cfg.TestOptions.MaxRetries; i++ {
 		log.Printf("--- Starting speed test for IP%s (Attempt %d/%d) ---", version, i+1, cfg.TestOptions.MaxRetries)
-		currentResults, err := cf.Run()
+		metrics.Attempts.Inc(version)
+		currentResults, err := runAttempt(ctx, cf, cfg.TestOptions.AttemptTimeoutMinutes)
+		if testConfig.Engine != "native" {
+			confirmOrRollbackUpdate(cfg, testConfig.Binary, err, dispatcher)
+		}
 
 		if err != nil {
 			log.Printf("Speed test for IP%s failed on attempt %d: %v", version, i+1, err)
 		} else if len(currentResults) > 0 {
 			log.Printf("Got %d results in this attempt.", len(currentResults))
 			finalResults = currentResults
+			// [新增] 保存每次尝试的结果到历史数据库
+			if _, _, _, hist := currentGlobals(); hist != nil {
+				err := hist.Append(history.Record{
+					Time:     time.Now(),
+					Version:  version,
+					Attempt:  i + 1,
+					Device:   cfg.DeviceName,
+					Operator: cfg.LineOperator,
+					Results:  currentResults,
+				})
+				if err != nil {
+					log.Printf("WARN: Failed to save results to history: %v", err)
+				}
+			}
 		}
 
 		if len(finalResults) >= cfg.TestOptions.MinResults {
@@ -183,33 +621,64 @@ func runTest(gc *gist.Client, cfg *config.Config, version string, notifiers []no
 			break
 		}
 
+		if ctx.Err() != nil {
+			log.Printf("Speed test for IP%s stopped: %v", version, ctx.Err())
+			break
+		}
 		if i < cfg.TestOptions.MaxRetries-1 {
 			delay := time.Duration(cfg.TestOptions.RetryDelay) * time.Second
 			log.Printf("Waiting for %v before next attempt...", delay)
-			time.Sleep(delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
 		}
 	}
 
+	// [新增] 收到退出信号时不发送失败通知也不安排延迟重试
+	if len(finalResults) == 0 && errors.Is(ctx.Err(), context.Canceled) {
+		log.Printf("Speed test for IP%s was cancelled.", version)
+		state.recordResult(version, nil, ctx.Err())
+		return false
+	}
+
 	if len(finalResults) == 0 {
 		log.Printf("FATAL: Speed test for IP%s failed after %d immediate attempts.", version, cfg.TestOptions.MaxRetries)
+		dispatcher.Dispatch(notifier.Event{
+			Type:      notifier.EventFailure,
+			Title:     fmt.Sprintf("IP%s speed test failed", version),
+			Message:   fmt.Sprintf("Device %s (%s) got no IP%s results after %d attempts.", cfg.DeviceName, cfg.LineOperator, version, cfg.TestOptions.MaxRetries),
+			Device:    cfg.DeviceName,
+			Operator:  cfg.LineOperator,
+			IPVersion: version,
+		})
 		// [新增] 检查是否启用延迟重试
-		if cfg.TestOptions.DelayedRetry.Enabled && cfg.TestOptions.DelayedRetry.DelayMinutes > 0 {
+		if daemonMode && cfg.TestOptions.DelayedRetry.Enabled && cfg.TestOptions.DelayedRetry.DelayMinutes > 0 {
 			// 在一个新的 goroutine 中安排延迟重试，不会阻塞后续代码
-			go scheduleDelayedRetry(version)
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				scheduleDelayedRetry(version)
+			}()
 		}
-		return // 结束当前测试流程
+		metrics.Failures.Inc(version)
+		state.recordResult(version, nil, fmt.Errorf("no results after %d attempts", cfg.TestOptions.MaxRetries))
+		return false // 结束当前测试流程
 	}
 
 	log.Println("Sorting final results...")
-	sort.Slice(finalResults, func(i, j int) bool {
-		if finalResults[i].LossPct != finalResults[j].LossPct {
-			return finalResults[i].LossPct < finalResults[j].LossPct
-		}
-		if finalResults[i].LatencyMs != finalResults[j].LatencyMs {
-			return finalResults[i].LatencyMs < finalResults[j].LatencyMs
-		}
-		return finalResults[i].DLMBps > finalResults[j].DLMBps
-	})
+	finalResults = sortedResults(finalResults)
+
+	best := finalResults[0]
+	// 指标使用基本单位：延迟为秒，速度为字节/秒
+	metrics.BestLatency.Set(best.Latency()/1000, version, cfg.DeviceName, cfg.LineOperator)
+	metrics.BestLoss.Set(best.LossPct, version, cfg.DeviceName, cfg.LineOperator)
+	metrics.BestSpeed.Set(best.DLMBps*1024*1024, version, cfg.DeviceName, cfg.LineOperator)
+
+	// [新增] 将最优 IP 写入 Cloudflare DNS 记录
+	if cfg.DNS.Enabled {
+		updateDNS(cfg, version, finalResults)
+	}
 
 	var uploadResults []models.DeviceResult
 	if len(finalResults) > cfg.TestOptions.GistUploadLimit {
@@ -223,16 +692,60 @@ func runTest(gc *gist.Client, cfg *config.Config, version string, notifiers []no
 		Timestamp: time.Now().Format(time.RFC3339),
 		Results:   uploadResults,
 	}
+	// [新增] 未开启扩展字段时按旧格式上传，兼容旧的读取方
+	if cfg.TestOptions.ExtendedResults {
+		gistContent.SchemaVersion = models.SchemaExtended
+	} else {
+		gistContent.Results = make([]models.DeviceResult, len(uploadResults))
+		for i, r := range uploadResults {
+			gistContent.Results[i] = r.Basic()
+		}
+	}
 
-	log.Printf("Uploading %d results to Gist as JSON with filename: %s", len(uploadResults), finalGistFilename)
-	if err := gc.PushResults(cfg.Gist.GistID, finalGistFilename, gistContent); err != nil {
-		if strings.Contains(err.Error(), "404") {
-			log.Printf("FATAL: Gist update for %s failed with 404 Not Found. Please check Gist ID and GITHUB_TOKEN permissions.", finalGistFilename)
-		} else {
-			log.Printf("Gist update for %s failed: %v", finalGistFilename, err)
+	log.Printf("Uploading %d results as JSON with filename %s to %d storage target(s)", len(uploadResults), finalGistFilename, len(sinks))
+	var stored, failed []string
+	for _, res := range storage.StoreAll(sinks, finalGistFilename, gistContent) {
+		if res.Err == nil {
+			metrics.Uploads.Inc(version, res.Name, "success")
+			log.Printf("Upload of %s to %s succeeded.", finalGistFilename, res.Name)
+			stored = append(stored, res.Name)
+			continue
 		}
-		return
+		log.Printf("Upload of %s to %s failed: %v", finalGistFilename, res.Name, res.Err)
+		metrics.Uploads.Inc(version, res.Name, "failure")
+		failed = append(failed, res.Name)
+		dispatcher.Dispatch(notifier.Event{
+			Type:      notifier.EventUploadFailure,
+			Title:     fmt.Sprintf("IP%s upload to %s failed", version, res.Name),
+			Message:   fmt.Sprintf("Device %s (%s) failed to upload %s to %s: %v", cfg.DeviceName, cfg.LineOperator, finalGistFilename, res.Name, res.Err),
+			Device:    cfg.DeviceName,
+			Operator:  cfg.LineOperator,
+			IPVersion: version,
+			Results:   uploadResults,
+		})
+	}
+	if len(stored) == 0 {
+		log.Printf("FATAL: Results for IP%s could not be stored to any target.", version)
+		state.recordResult(version, uploadResults, fmt.Errorf("upload to %s failed", strings.Join(failed, ", ")))
+		return false
 	}
+	state.recordResult(version, uploadResults, nil)
 
-	log.Printf("--- Test for IP%s completed successfully ---", version)
-}
\ No newline at end of file
+	if len(failed) > 0 {
+		log.Printf("--- Test for IP%s completed, but uploads to %s failed ---", version, strings.Join(failed, ", "))
+	} else {
+		log.Printf("--- Test for IP%s completed successfully ---", version)
+	}
+	dispatcher.Dispatch(notifier.Event{
+		Type:  notifier.EventSuccess,
+		Title: fmt.Sprintf("IP%s speed test succeeded", version),
+		Message: fmt.Sprintf("Device %s (%s) uploaded %d IP%s results to %s. Best: %s, %dms, %.2fMB/s\n\n%s",
+			cfg.DeviceName, cfg.LineOperator, len(uploadResults), version, strings.Join(stored, ", "), best.IP, best.LatencyMs, best.DLMBps,
+			notifier.FormatResults(uploadResults, cfg.Notifications.TopN)),
+		Device:    cfg.DeviceName,
+		Operator:  cfg.LineOperator,
+		IPVersion: version,
+		Results:   uploadResults,
+	})
+	return true
+}
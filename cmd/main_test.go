@@ -0,0 +1,174 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/metrics"
+	"cfst-client/pkg/models"
+	"cfst-client/pkg/notifier"
+	"cfst-client/pkg/tester"
+)
+
+// pendingUpdate 模拟刚安装的更新：新程序为 script，旧程序保留为 .bak，并写入待确认标记
+func pendingUpdate(t *testing.T, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "cfst")
+	writeFile(t, bin, "#!/bin/sh\n"+script+"\n", 0755)
+	writeFile(t, bin+".bak", "#!/bin/sh\n# old\n", 0755)
+	writeFile(t, bin+".pending", "v2.0.0\n", 0644)
+	return bin
+}
+
+func writeFile(t *testing.T, path, data string, perm os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfirmOrRollbackUpdate(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       string
+		wantRollback bool
+	}{
+		{"exit status keeps update", "exit 1", false},
+		{"success keeps update", "exit 0", false},
+		{"killed by signal rolls back", "kill -KILL $$", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := pendingUpdate(t, tt.script)
+			out := filepath.Join(filepath.Dir(bin), "result.csv")
+			_, runErr := tester.NewCFSpeedTester(bin, out, "dev", "op", nil).Run(context.Background())
+
+			confirmOrRollbackUpdate(&config.Config{}, bin, runErr, notifier.NewDispatcher(config.NotificationEventsConfig{}))
+
+			data, err := os.ReadFile(bin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rolledBack := string(data) == "#!/bin/sh\n# old\n"
+			if rolledBack != tt.wantRollback {
+				t.Fatalf("rolled back = %v, want %v (run error: %v)", rolledBack, tt.wantRollback, runErr)
+			}
+			if _, err := os.Stat(bin + ".pending"); !os.IsNotExist(err) {
+				t.Errorf("pending marker still exists: %v", err)
+			}
+			_, err = os.Stat(bin + ".rejected")
+			if rejected := err == nil; rejected != tt.wantRollback {
+				t.Errorf("rejected marker exists = %v, want %v", rejected, tt.wantRollback)
+			}
+		})
+	}
+}
+
+func TestConfirmOrRollbackUpdateKeepsPendingWhenStopped(t *testing.T) {
+	bin := pendingUpdate(t, "sleep 5")
+	out := filepath.Join(filepath.Dir(bin), "result.csv")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, runErr := tester.NewCFSpeedTester(bin, out, "dev", "op", nil).Run(ctx)
+
+	confirmOrRollbackUpdate(&config.Config{}, bin, runErr, notifier.NewDispatcher(config.NotificationEventsConfig{}))
+
+	if _, err := os.Stat(bin + ".pending"); err != nil {
+		t.Errorf("pending marker removed after a cancelled run: %v", err)
+	}
+}
+
+func TestWithTimeoutMinutes(t *testing.T) {
+	for _, minutes := range []int{0, -1} {
+		ctx, cancel := withTimeoutMinutes(context.Background(), minutes)
+		if _, ok := ctx.Deadline(); ok {
+			t.Errorf("withTimeoutMinutes(%d) set a deadline, want none", minutes)
+		}
+		cancel()
+		if ctx.Err() != context.Canceled {
+			t.Errorf("withTimeoutMinutes(%d) cancel: err = %v", minutes, ctx.Err())
+		}
+	}
+
+	start := time.Now()
+	ctx, cancel := withTimeoutMinutes(context.Background(), 2)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || deadline.Before(start.Add(2*time.Minute)) || deadline.After(time.Now().Add(2*time.Minute)) {
+		t.Errorf("withTimeoutMinutes(2) deadline = %v, %v, want 2 minutes from now", deadline, ok)
+	}
+
+	// 单次尝试的超时不能超过整轮测试的超时，整轮测试被取消时尝试也随之取消
+	runCtx, cancelRun := context.WithTimeout(context.Background(), time.Minute)
+	attemptCtx, cancelAttempt := withTimeoutMinutes(runCtx, 10)
+	defer cancelAttempt()
+	if d, _ := attemptCtx.Deadline(); d.After(time.Now().Add(time.Minute)) {
+		t.Errorf("attempt deadline %v is later than the run deadline", d)
+	}
+	cancelRun()
+	if attemptCtx.Err() != context.Canceled {
+		t.Errorf("attempt err after run cancel = %v, want context.Canceled", attemptCtx.Err())
+	}
+}
+
+// fakeTester 是返回固定结果的测速引擎，记录收到的 context 是否带有截止时间
+type fakeTester struct {
+	results     []models.DeviceResult
+	err         error
+	hadDeadline bool
+}
+
+func (f *fakeTester) Run(ctx context.Context) ([]models.DeviceResult, error) {
+	_, f.hadDeadline = ctx.Deadline()
+	return f.results, f.err
+}
+
+// durationCount 返回测速耗时直方图当前的观测次数
+func durationCount(t *testing.T) int {
+	t.Helper()
+	var buf bytes.Buffer
+	metrics.Default.Expose(&buf)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, "cfst_client_cfst_duration_seconds_count "); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n
+		}
+	}
+	t.Fatal("duration histogram is not exposed")
+	return 0
+}
+
+func TestRunAttemptRecordsDuration(t *testing.T) {
+	before := durationCount(t)
+	ok := &fakeTester{results: []models.DeviceResult{{IP: "1.1.1.1"}}}
+	if got, err := runAttempt(context.Background(), ok, 30); err != nil || len(got) != 1 {
+		t.Fatalf("runAttempt = %v, %v", got, err)
+	}
+	if !ok.hadDeadline {
+		t.Error("attempt ran without the attempt timeout")
+	}
+	failed := &fakeTester{err: errors.New("boom")}
+	if _, err := runAttempt(context.Background(), failed, 0); err == nil {
+		t.Error("runAttempt hid the engine error")
+	}
+	if failed.hadDeadline {
+		t.Error("attempt_timeout_minutes 0 still set a deadline")
+	}
+	if got := durationCount(t) - before; got != 2 {
+		t.Errorf("duration histogram recorded %d attempts, want 2 (successful and failed)", got)
+	}
+}
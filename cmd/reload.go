@@ -0,0 +1,129 @@
+// File: cmd/reload.go
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/history"
+	"cfst-client/pkg/notifier"
+	"cfst-client/pkg/storage"
+)
+
+// configWatchInterval 是检查 config.yml 是否变化的间隔
+const configWatchInterval = 5 * time.Second
+
+// globalsMu 保护当前生效的配置及由其构建的全局对象
+var (
+	globalsMu    sync.RWMutex
+	globalConfig *config.Config
+)
+
+// applyConfig 根据新配置重建通知器、存储目标和历史数据库，并原子地替换全局对象
+func applyConfig(cfg *config.Config) {
+	dispatcher := buildDispatcher(cfg)
+	storages := buildStorages(cfg)
+	hist := reuseOrOpenHistory(cfg)
+
+	globalsMu.Lock()
+	globalConfig = cfg
+	globalDispatcher = dispatcher
+	globalStorages = storages
+	globalHistory = hist
+	globalsMu.Unlock()
+
+	state.setIdentity(cfg.DeviceName, cfg.LineOperator)
+	state.setHistory(hist)
+}
+
+// reuseOrOpenHistory 在历史数据库的启用状态和文件不变时复用已打开的 Store，只更新保留策略，
+// 避免热加载时重复读取整个文件，也避免同一文件同时被两个 Store 写入
+func reuseOrOpenHistory(cfg *config.Config) *history.Store {
+	old, _, _, hist := currentGlobals()
+	if old != nil && hist != nil && cfg.History.Enabled && old.History.File == cfg.History.File {
+		hist.SetLimits(historyRetention(cfg), cfg.History.MaxRecords)
+		return hist
+	}
+	return openHistory(cfg)
+}
+
+// currentGlobals 返回当前生效的配置及全局对象
+func currentGlobals() (*config.Config, []storage.Storage, *notifier.Dispatcher, *history.Store) {
+	globalsMu.RLock()
+	defer globalsMu.RUnlock()
+	return globalConfig, globalStorages, globalDispatcher, globalHistory
+}
+
+// watchConfig 定期检查 config.yml，内容变化且校验通过时热加载新配置；
+// 新配置无效时保留旧配置继续运行。ctx 取消时返回。
+func watchConfig(ctx context.Context, initial []byte) {
+	lastSum := sha256.Sum256(initial)
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if bytes.Equal(sum[:], lastSum[:]) {
+			continue
+		}
+		lastSum = sum
+		reloadConfig()
+	}
+}
+
+// reloadConfig 加载并应用最新配置，必要时重新安排 cron 任务。
+// api、metrics 和 Telegram 机器人只在启动时创建，它们的变更只记录警告，需重启后生效。
+func reloadConfig() {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Printf("WARN: config.yml changed but is invalid, keeping the previous config: %v", err)
+		return
+	}
+
+	old, _, _, _ := currentGlobals()
+	if old != nil {
+		changes := config.Diff(old, cfg)
+		if len(changes) == 0 {
+			return
+		}
+		log.Printf("Config reloaded with %d change(s):", len(changes))
+		for _, c := range changes {
+			log.Printf("  %s", c)
+		}
+	}
+	applyConfig(cfg)
+
+	if old == nil || old.Cron != cfg.Cron {
+		if err := state.reschedule(cfg.Cron, scheduledRun); err != nil {
+			// 校验已检查过 cron 表达式，这里只可能是调度器内部错误
+			log.Printf("ERROR: Failed to reschedule cron job: %v", err)
+		} else if cfg.Cron != "" {
+			log.Printf("Rescheduled tests with cron expression: %s", cfg.Cron)
+		} else {
+			log.Println("Cron expression removed; scheduled tests are disabled.")
+		}
+	}
+	if old != nil && (old.API != cfg.API || old.Metrics != cfg.Metrics) {
+		log.Println("WARN: Changes to 'api' and 'metrics' take effect after a restart.")
+	}
+	if old != nil && old.Notifications.Telegram != cfg.Notifications.Telegram &&
+		(old.Notifications.Telegram.Commands || cfg.Notifications.Telegram.Commands) {
+		log.Println("WARN: Changes to the Telegram bot take effect after a restart.")
+	}
+}
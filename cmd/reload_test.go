@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/notifier"
+)
+
+// writeConfig 将示例配置经过 replacements（旧文本、新文本交替）替换后写入 configPath
+func writeConfig(t *testing.T, replacements ...string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "config", "config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for i := 0; i+1 < len(replacements); i += 2 {
+		if !strings.Contains(text, replacements[i]) {
+			t.Fatalf("example config does not contain %q", replacements[i])
+		}
+		text = strings.Replace(text, replacements[i], replacements[i+1], 1)
+	}
+	if err := os.WriteFile(configPath, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useConfigFile 让 configPath 指向临时配置目录中的 config.yml，并在测试结束后停止调度器
+func useConfigFile(t *testing.T) {
+	t.Helper()
+	useGlobals(t, nil, nil)
+	oldPath := configPath
+	configPath = filepath.Join(configDir, "config.yml")
+	t.Cleanup(func() {
+		configPath = oldPath
+		state.stopScheduler()
+	})
+}
+
+func TestReloadConfigKeepsOldConfigWhenInvalid(t *testing.T) {
+	useConfigFile(t)
+	writeConfig(t)
+	reloadConfig()
+	old, _, oldDispatcher, _ := currentGlobals()
+	if old == nil {
+		t.Fatal("valid config was not applied")
+	}
+
+	writeConfig(t, `cron: "0 0 * * *"`, `cron: "not a cron"`)
+	reloadConfig()
+	cfg, _, dispatcher, _ := currentGlobals()
+	if cfg != old || dispatcher != oldDispatcher {
+		t.Error("an invalid config replaced the previous config")
+	}
+
+	writeConfig(t, "device_name:", "unknown_key: 1\ndevice_name:")
+	reloadConfig()
+	if cfg, _, _, _ := currentGlobals(); cfg != old {
+		t.Error("a config with an unknown key replaced the previous config")
+	}
+}
+
+func TestReloadConfigSwapsDispatcherAndReschedules(t *testing.T) {
+	t.Setenv("BARK_DEVICE_KEY", "key") // 启用一个通知渠道，使分发器包含通知器
+	useConfigFile(t)
+	writeConfig(t)
+	reloadConfig()
+	_, _, oldDispatcher, _ := currentGlobals()
+	oldEntry := state.cronEntry
+	if !oldDispatcher.Enabled(notifier.EventSuccess) || oldEntry == 0 {
+		t.Fatalf("initial config: success enabled = %v, cron entry = %d", oldDispatcher.Enabled(notifier.EventSuccess), oldEntry)
+	}
+
+	writeConfig(t,
+		`cron: "0 0 * * *"`, `cron: "*/5 * * * *"`,
+		"success: true ", "success: false ")
+	reloadConfig()
+
+	cfg, _, dispatcher, _ := currentGlobals()
+	if cfg.Cron != "*/5 * * * *" {
+		t.Errorf("cron = %q after reload", cfg.Cron)
+	}
+	if dispatcher == oldDispatcher || dispatcher.Enabled(notifier.EventSuccess) {
+		t.Error("dispatcher was not rebuilt from the new notification events")
+	}
+	if !dispatcher.Enabled(notifier.EventFailure) {
+		t.Error("new dispatcher dropped the failure event")
+	}
+
+	state.mu.Lock()
+	entry := state.scheduler.Entry(state.cronEntry)
+	oldStillScheduled := state.scheduler.Entry(oldEntry).ID != 0
+	state.mu.Unlock()
+	if entry.ID == oldEntry || oldStillScheduled {
+		t.Errorf("cron entry %d was not replaced (old entry still scheduled: %v)", entry.ID, oldStillScheduled)
+	}
+	from := time.Date(2024, 1, 1, 0, 1, 0, 0, time.Local)
+	if next := entry.Schedule.Next(from); !next.Equal(from.Add(4 * time.Minute)) {
+		t.Errorf("next run after %v = %v, want every 5 minutes", from, next)
+	}
+}
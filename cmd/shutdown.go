@@ -0,0 +1,162 @@
+// File: cmd/shutdown.go
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"cfst-client/pkg/api"
+	"cfst-client/pkg/notifier"
+)
+
+// pendingRetriesFile 保存退出时尚未执行的延迟重试，下次启动时恢复
+const pendingRetriesFile = "pending_retries.json"
+
+// httpServer 是可以优雅关闭的 HTTP 服务（*http.Server 或 *api.Server）
+type httpServer interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdown 在收到退出信号后调用：停止定时任务并先保存尚未执行的延迟重试，
+// 然后在 shutdown_grace_seconds 内完成全部清理，保证在容器被强制终止前退出。
+// 宽限期的前一半等待当前测试和后台通知自行结束，超时则通过 cancelRuns 终止测试，
+// 再用四分之一等待测试停止，最后四分之一用于发送 shutdown 事件；HTTP 服务同时关闭。
+func shutdown(cancelRuns context.CancelFunc, servers ...httpServer) {
+	shuttingDown.Store(true)
+	cfg, _, dispatcher, _ := currentGlobals()
+	grace := 8 * time.Second
+	if cfg != nil {
+		grace = time.Duration(cfg.ShutdownGraceSeconds) * time.Second
+	}
+	start := time.Now()
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(grace))
+	defer cancel()
+	cancelAt := start.Add(grace / 2)
+	notifyAt := start.Add(grace - grace/4)
+
+	state.stopScheduler()
+	// 先保存，即使之后的等待被强制终止也不会丢失
+	pending := state.drainPending()
+	savePendingRetries(pending)
+	log.Printf("Received shutdown signal. Scheduler stopped; shutting down within %v...", grace)
+
+	// HTTP 服务与测试的等待同时关闭，共用同一个截止时间
+	var serversDone sync.WaitGroup
+	for _, srv := range servers {
+		serversDone.Add(1)
+		go func(srv httpServer) {
+			defer serversDone.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Printf("WARN: HTTP server did not shut down cleanly: %v", err)
+			}
+		}(srv)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		runLock.Lock() // 当前测试结束后才能获得锁
+		runLock.Unlock()
+		background.Wait()
+		close(done)
+	}()
+	outcome := "no test was running or the running test finished"
+	select {
+	case <-done:
+	case <-time.After(time.Until(cancelAt)):
+		log.Println("Half of the grace period has passed. Cancelling in-flight tests...")
+		cancelRuns()
+		outcome = "the running test was cancelled during shutdown"
+		select {
+		case <-done:
+		case <-time.After(time.Until(notifyAt)):
+			log.Println("WARN: The cancelled test did not stop in time; exiting anyway.")
+			outcome = "the running test did not stop and was abandoned"
+		}
+	}
+
+	// 等待期间结束的测试可能又安排了延迟重试
+	if more := state.drainPending(); len(more) > 0 {
+		pending = append(pending, more...)
+		savePendingRetries(pending)
+	}
+
+	if cfg != nil {
+		sent := make(chan struct{})
+		go func() {
+			defer close(sent)
+			dispatcher.Dispatch(notifier.Event{
+				Type:     notifier.EventShutdown,
+				Title:    "cfst-client shutting down",
+				Message:  fmt.Sprintf("Device %s (%s) is shutting down: %s; %d pending delayed retries saved.", cfg.DeviceName, cfg.LineOperator, outcome, len(pending)),
+				Device:   cfg.DeviceName,
+				Operator: cfg.LineOperator,
+			})
+		}()
+		select {
+		case <-sent:
+		case <-ctx.Done():
+			log.Println("WARN: Shutdown notification was not sent before the shutdown deadline.")
+		}
+	}
+
+	serversStopped := make(chan struct{})
+	go func() {
+		serversDone.Wait()
+		close(serversStopped)
+	}()
+	select {
+	case <-serversStopped:
+	case <-ctx.Done():
+	}
+	log.Printf("Shutdown complete in %v.", time.Since(start).Round(time.Millisecond))
+}
+
+// savePendingRetries 将延迟重试写入配置目录，列表为空时删除已保存的文件
+func savePendingRetries(pending []api.PendingRetry) {
+	path := filepath.Join(configDir, pendingRetriesFile)
+	if len(pending) == 0 {
+		_ = os.Remove(path)
+		return
+	}
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("WARN: Failed to save %d pending delayed retries: %v", len(pending), err)
+		return
+	}
+	log.Printf("Saved %d pending delayed retries to %s.", len(pending), path)
+}
+
+// restorePendingRetries 恢复上次退出时保存的延迟重试。
+// 已过期的重试会被丢弃，因为启动时会立即执行一次完整测试。
+func restorePendingRetries() {
+	path := filepath.Join(configDir, pendingRetriesFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	_ = os.Remove(path)
+
+	var pending []api.PendingRetry
+	if err := json.Unmarshal(data, &pending); err != nil {
+		log.Printf("WARN: Ignoring invalid %s: %v", path, err)
+		return
+	}
+	for _, p := range pending {
+		if !p.DueAt.After(time.Now()) {
+			log.Printf("DELAYED RETRY [IP%s]: Saved retry due at %s has expired; the startup test replaces it.", p.Version, p.DueAt.Format("2006-01-02 15:04:05"))
+			continue
+		}
+		log.Printf("DELAYED RETRY [IP%s]: Restored retry due at %s.", p.Version, p.DueAt.Format("2006-01-02 15:04:05"))
+		armDelayedRetry(p.Version, p.DueAt)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/api"
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/notifier"
+)
+
+// useGlobals 替换当前生效的配置、通知器和配置目录，并清空存储目标和历史数据库，测试结束后恢复
+func useGlobals(t *testing.T, cfg *config.Config, dispatcher *notifier.Dispatcher) {
+	t.Helper()
+	globalsMu.Lock()
+	oldCfg, oldDispatcher, oldStorages, oldHistory := globalConfig, globalDispatcher, globalStorages, globalHistory
+	globalConfig, globalDispatcher, globalStorages, globalHistory = cfg, dispatcher, nil, nil
+	globalsMu.Unlock()
+	oldDir := configDir
+	configDir = t.TempDir()
+	t.Cleanup(func() {
+		globalsMu.Lock()
+		globalConfig, globalDispatcher, globalStorages, globalHistory = oldCfg, oldDispatcher, oldStorages, oldHistory
+		globalsMu.Unlock()
+		configDir = oldDir
+		shuttingDown.Store(false)
+		state.drainPending()
+	})
+}
+
+// eventRecorder 记录收到的通知标题和正文
+type eventRecorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *eventRecorder) Notify(title, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, title+": "+message)
+	return nil
+}
+
+func (r *eventRecorder) all() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+// deadlineServer 记录 Shutdown 收到的截止时间
+type deadlineServer struct {
+	deadline chan time.Time
+}
+
+func (s *deadlineServer) Shutdown(ctx context.Context) error {
+	d, _ := ctx.Deadline()
+	s.deadline <- d
+	return nil
+}
+
+func TestPendingRetriesRoundTrip(t *testing.T) {
+	useGlobals(t, nil, nil)
+	now := time.Now()
+	saved := []api.PendingRetry{
+		{Version: "v4", DueAt: now.Add(-time.Minute)}, // 已过期，恢复时丢弃
+		{Version: "v4", DueAt: now.Add(time.Hour)},
+		{Version: "v6", DueAt: now.Add(2 * time.Hour)},
+	}
+	savePendingRetries(saved)
+	path := filepath.Join(configDir, pendingRetriesFile)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("pending retries were not saved: %v", err)
+	}
+
+	restorePendingRetries()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed after restoring: %v", pendingRetriesFile, err)
+	}
+	got := state.drainPending()
+	if len(got) != 2 {
+		t.Fatalf("restored %+v, want the two retries that are still due", got)
+	}
+	for i, want := range saved[1:] {
+		if got[i].Version != want.Version || !got[i].DueAt.Equal(want.DueAt) {
+			t.Errorf("restored[%d] = %+v, want %+v", i, got[i], want)
+		}
+	}
+
+	// 没有待执行的重试时删除已保存的文件
+	savePendingRetries(saved[1:])
+	savePendingRetries(nil)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("saving an empty list left %s behind: %v", pendingRetriesFile, err)
+	}
+}
+
+func TestRestorePendingRetriesInvalidFile(t *testing.T) {
+	useGlobals(t, nil, nil)
+	path := filepath.Join(configDir, pendingRetriesFile)
+
+	// 文件不存在时什么也不做
+	restorePendingRetries()
+	if got := state.drainPending(); len(got) != 0 {
+		t.Errorf("restored %+v without a saved file", got)
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	restorePendingRetries()
+	if got := state.drainPending(); len(got) != 0 {
+		t.Errorf("restored %+v from a corrupt file", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("corrupt %s was not removed: %v", pendingRetriesFile, err)
+	}
+}
+
+func TestShutdownGracePeriodSplit(t *testing.T) {
+	tests := []struct {
+		name         string
+		stopOnCancel bool
+		wantOutcome  string
+	}{
+		{"test stops after cancel", true, "the running test was cancelled during shutdown"},
+		{"test ignores cancel", false, "the running test did not stop and was abandoned"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &eventRecorder{}
+			cfg := &config.Config{ShutdownGraceSeconds: 2, DeviceName: "dev", LineOperator: "op"}
+			useGlobals(t, cfg, notifier.NewDispatcher(config.NotificationEventsConfig{Shutdown: true}, rec))
+			state.addPending("v6", time.Now().Add(time.Hour))
+
+			// 模拟一个正在运行的测试：持有 runLock，直到被取消（或测试结束）才释放
+			runLock.Lock()
+			released := make(chan struct{})
+			release := sync.OnceFunc(func() { runLock.Unlock(); close(released) })
+			t.Cleanup(release)
+			var cancelledAt time.Time
+			cancelRuns := func() {
+				cancelledAt = time.Now()
+				if tt.stopOnCancel {
+					go release()
+				}
+			}
+			srv := &deadlineServer{deadline: make(chan time.Time, 1)}
+
+			start := time.Now()
+			shutdown(cancelRuns, srv)
+			elapsed := time.Since(start)
+
+			if !shuttingDown.Load() {
+				t.Error("shuttingDown was not set")
+			}
+			if cancelledAt.IsZero() {
+				t.Fatal("the running test was never cancelled")
+			}
+			if d := cancelledAt.Sub(start); d < 900*time.Millisecond || d > 1500*time.Millisecond {
+				t.Errorf("test cancelled after %v, want about half of the 2s grace period", d)
+			}
+			if elapsed > 2200*time.Millisecond {
+				t.Errorf("shutdown took %v, want at most the 2s grace period", elapsed)
+			}
+			if !tt.stopOnCancel && elapsed < 1400*time.Millisecond {
+				t.Errorf("shutdown took %v, want it to wait until three quarters of the grace period", elapsed)
+			}
+			select {
+			case d := <-srv.deadline:
+				if want := start.Add(2 * time.Second); d.Sub(want).Abs() > 100*time.Millisecond {
+					t.Errorf("server shutdown deadline = %v, want %v", d, want)
+				}
+			default:
+				t.Error("HTTP server was not shut down")
+			}
+
+			msgs := rec.all()
+			if len(msgs) != 1 || !strings.Contains(msgs[0], tt.wantOutcome) || !strings.Contains(msgs[0], "1 pending delayed retries saved") {
+				t.Errorf("shutdown notifications = %q, want one mentioning %q and the saved retry", msgs, tt.wantOutcome)
+			}
+			if _, err := os.Stat(filepath.Join(configDir, pendingRetriesFile)); err != nil {
+				t.Errorf("pending retry was not saved: %v", err)
+			}
+		})
+	}
+}
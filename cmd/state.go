@@ -0,0 +1,259 @@
+// File: cmd/state.go
+
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"cfst-client/pkg/api"
+	"cfst-client/pkg/history"
+	"cfst-client/pkg/models"
+	"github.com/robfig/cron/v3"
+)
+
+// runState 记录守护进程的运行状态，供 API 等外部接口查询
+type runState struct {
+	mu           sync.Mutex
+	running      bool
+	paused       bool
+	runStartedAt time.Time
+	device       string
+	operator     string
+	lastResults  map[string]api.VersionResult
+	progress     map[string]api.Progress
+	pending      map[int]*pendingRetry
+	nextPending  int
+	scheduler    *cron.Cron
+	cronEntry    cron.EntryID
+	history      *history.Store
+}
+
+// pendingRetry 是一个已安排的延迟重试
+type pendingRetry struct {
+	version string
+	dueAt   time.Time
+	timer   *time.Timer
+}
+
+var state = &runState{
+	lastResults: make(map[string]api.VersionResult),
+	pending:     make(map[int]*pendingRetry),
+}
+
+// setRunning 在持有 runLock 时调用，标记测试开始或结束
+func (s *runState) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = running
+	if running {
+		s.runStartedAt = time.Now()
+	}
+	s.progress = nil
+}
+
+// setProgress 记录某个 IP 版本的实时进度
+func (s *runState) setProgress(version string, p api.Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.progress == nil {
+		s.progress = make(map[string]api.Progress)
+	}
+	s.progress[version] = p
+}
+
+// setIdentity 记录当前配置中的设备名与运营商
+func (s *runState) setIdentity(device, operator string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.device = device
+	s.operator = operator
+}
+
+// reschedule 将定时任务替换为新的 cron 表达式，spec 为空时取消定时任务。
+// 新任务添加成功后才会移除旧任务，失败时保留原有调度。
+func (s *runState) reschedule(spec string, job func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scheduler == nil {
+		s.scheduler = cron.New()
+		s.scheduler.Start()
+	}
+	var id cron.EntryID
+	if spec != "" {
+		var err error
+		if id, err = s.scheduler.AddFunc(spec, job); err != nil {
+			return err
+		}
+	}
+	if s.cronEntry != 0 {
+		s.scheduler.Remove(s.cronEntry)
+	}
+	s.cronEntry = id
+	return nil
+}
+
+// setPaused 暂停或恢复定时任务，状态发生变化时返回 true。
+// 暂停只影响 cron 触发的测试，手动触发和延迟重试不受影响。
+func (s *runState) setPaused(paused bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.paused != paused
+	s.paused = paused
+	return changed
+}
+
+// isPaused 判断定时任务是否已暂停
+func (s *runState) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// setHistory 记录当前使用的历史数据库
+func (s *runState) setHistory(h *history.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = h
+}
+
+// History 实现 api.HistoryBackend
+func (s *runState) History() *history.Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.history
+}
+
+// recordResult 记录某个 IP 版本最近一次测试的结果
+func (s *runState) recordResult(version string, results []models.DeviceResult, err error) {
+	res := api.VersionResult{
+		Version:    version,
+		Success:    err == nil,
+		FinishedAt: time.Now(),
+		Results:    results,
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastResults[version] = res
+}
+
+// addPending 登记一个延迟重试并返回其 ID
+func (s *runState) addPending(version string, dueAt time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextPending++
+	s.pending[s.nextPending] = &pendingRetry{version: version, dueAt: dueAt}
+	return s.nextPending
+}
+
+// attachTimer 关联延迟重试的定时器，以便之后取消
+func (s *runState) attachTimer(id int, timer *time.Timer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.pending[id]; ok {
+		p.timer = timer
+	}
+}
+
+// removePending 在延迟重试触发后将其移除
+func (s *runState) removePending(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, id)
+}
+
+// drainPending 停止并移除所有延迟重试，返回它们的版本和计划时间
+func (s *runState) drainPending() []api.PendingRetry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []api.PendingRetry
+	for id, p := range s.pending {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		list = append(list, api.PendingRetry{Version: p.version, DueAt: p.dueAt})
+		delete(s.pending, id)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DueAt.Before(list[j].DueAt) })
+	return list
+}
+
+// stopScheduler 停止 cron 调度，已在运行的任务不受影响
+func (s *runState) stopScheduler() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+		s.cronEntry = 0
+	}
+}
+
+// Status 实现 api.Backend
+func (s *runState) Status() api.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := api.Status{
+		State:          "idle",
+		Paused:         s.paused,
+		Device:         s.device,
+		Operator:       s.operator,
+		PendingRetries: []api.PendingRetry{},
+		LastResults:    make(map[string]api.VersionResult, len(s.lastResults)),
+	}
+	if s.running {
+		st.State = "running"
+		started := s.runStartedAt
+		st.RunStartedAt = &started
+	}
+	if s.scheduler != nil && s.cronEntry != 0 && !s.paused {
+		if next := s.scheduler.Entry(s.cronEntry).Next; !next.IsZero() {
+			st.NextRun = &next
+		}
+	}
+	for _, p := range s.pending {
+		st.PendingRetries = append(st.PendingRetries, api.PendingRetry{Version: p.version, DueAt: p.dueAt})
+	}
+	sort.Slice(st.PendingRetries, func(i, j int) bool {
+		return st.PendingRetries[i].DueAt.Before(st.PendingRetries[j].DueAt)
+	})
+	for k, v := range s.lastResults {
+		st.LastResults[k] = v
+	}
+	if s.running && len(s.progress) > 0 {
+		st.Progress = make(map[string]api.Progress, len(s.progress))
+		for k, v := range s.progress {
+			st.Progress[k] = v
+		}
+	}
+	return st
+}
+
+// TriggerRun 实现 api.Backend
+func (s *runState) TriggerRun() bool {
+	return s.startRun() == nil
+}
+
+// startRun 在后台启动一次完整测试，无法启动时返回 errShuttingDown 或 errRunInProgress。
+// 在返回前获取 runLock 并交给后台测试，避免返回“已启动”后测试却因锁被占用而被跳过
+func (s *runState) startRun() error {
+	if shuttingDown.Load() {
+		return errShuttingDown
+	}
+	if !runLock.TryLock() {
+		return errRunInProgress
+	}
+	if shuttingDown.Load() {
+		runLock.Unlock()
+		return errShuttingDown
+	}
+	go func() {
+		defer runLock.Unlock()
+		_ = runAllLocked(shutdownCtx)
+	}()
+	return nil
+}
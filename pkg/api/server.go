@@ -0,0 +1,234 @@
+// File: pkg/api/server.go
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cfst-client/pkg/history"
+	"cfst-client/pkg/models"
+)
+
+// VersionResult 是某个 IP 版本最近一次测试的结果
+type VersionResult struct {
+	Version    string                `json:"version"`
+	Success    bool                  `json:"success"`
+	Error      string                `json:"error,omitempty"`
+	FinishedAt time.Time             `json:"finished_at"`
+	Results    []models.DeviceResult `json:"results"`
+}
+
+// PendingRetry 是一个已安排但尚未执行的延迟重试
+type PendingRetry struct {
+	Version string    `json:"version"`
+	DueAt   time.Time `json:"due_at"`
+}
+
+// Progress 是正在运行的测试的实时进度
+type Progress struct {
+	Phase     string    `json:"phase"` // latency 或 download
+	Done      int       `json:"done"`
+	Total     int       `json:"total"`
+	Available int       `json:"available,omitempty"` // 延迟测速阶段已找到的可用 IP 数
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Status 是 /api/status 返回的运行状态
+type Status struct {
+	State          string                   `json:"state"`  // running 或 idle
+	Paused         bool                     `json:"paused"` // 定时任务是否已暂停
+	RunStartedAt   *time.Time               `json:"run_started_at,omitempty"`
+	NextRun        *time.Time               `json:"next_run,omitempty"`
+	Device         string                   `json:"device"`
+	Operator       string                   `json:"operator"`
+	PendingRetries []PendingRetry           `json:"pending_retries"`
+	LastResults    map[string]VersionResult `json:"last_results"`
+	Progress       map[string]Progress      `json:"progress,omitempty"` // 按 IP 版本区分，仅在运行中返回
+}
+
+// Backend 由主程序实现，为 API 提供状态查询和手动触发能力
+type Backend interface {
+	Status() Status
+	// TriggerRun 异步启动一次完整测试，已有测试在运行时返回 false
+	TriggerRun() bool
+}
+
+// HistoryBackend 可由 Backend 额外实现，用于提供历史数据查询接口
+type HistoryBackend interface {
+	// History 返回当前的历史数据库，未启用时返回 nil
+	History() *history.Store
+}
+
+// Server 是内置的 HTTP 状态与控制接口
+type Server struct {
+	backend Backend
+	token   string
+	mux     *http.ServeMux
+
+	mu     sync.Mutex
+	srv    *http.Server // ListenAndServe 启动的服务
+	closed bool         // Shutdown 已调用，之后不再启动服务
+}
+
+// NewServer 创建一个新的 API 服务，token 为空时不启用认证
+func NewServer(backend Backend, token string) *Server {
+	s := &Server{
+		backend: backend,
+		token:   token,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /api/status", s.auth(s.handleStatus))
+	s.mux.HandleFunc("GET /api/results", s.auth(s.handleResults))
+	s.mux.HandleFunc("GET /api/results/{version}", s.auth(s.handleResults))
+	s.mux.HandleFunc("POST /api/run", s.auth(s.handleRun))
+	if hb, ok := backend.(HistoryBackend); ok {
+		s.mux.HandleFunc("GET /api/history/ip/{ip}", s.auth(func(w http.ResponseWriter, r *http.Request) {
+			s.handleIPTrend(w, r, hb.History())
+		}))
+		s.mux.HandleFunc("GET /api/history/regions", s.auth(func(w http.ResponseWriter, r *http.Request) {
+			s.handleRegionStats(w, r, hb.History())
+		}))
+	}
+	return s
+}
+
+// Handle 在同一监听端口上注册额外的处理器，与 API 使用相同的 Bearer Token 认证
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.HandleFunc(pattern, s.auth(h.ServeHTTP))
+}
+
+// ListenAndServe 在 addr 上启动 HTTP 服务，阻塞直到出错或 Shutdown 被调用
+func (s *Server) ListenAndServe(addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	s.srv = srv
+	s.mu.Unlock()
+	log.Printf("API server listening on %s", addr)
+	return srv.ListenAndServe()
+}
+
+// Shutdown 停止接受新连接并等待进行中的请求完成，直到 ctx 结束
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	srv := s.srv
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.backend.Status())
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	results := s.backend.Status().LastResults
+	version := r.PathValue("version")
+	if version == "" {
+		writeJSON(w, http.StatusOK, results)
+		return
+	}
+	res, ok := results[version]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no results for " + version})
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if !s.backend.TriggerRun() {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "a test is already in progress"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+// historySince 解析 days 查询参数，默认查询最近 7 天
+func historySince(r *http.Request) (time.Time, error) {
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return time.Time{}, fmt.Errorf("invalid days: %q", v)
+		}
+		days = n
+	}
+	return time.Now().AddDate(0, 0, -days), nil
+}
+
+func (s *Server) handleIPTrend(w http.ResponseWriter, r *http.Request, h *history.Store) {
+	if h == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "history is disabled"})
+		return
+	}
+	since, err := historySince(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	points, err := h.IPTrend(r.PathValue("ip"), since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, points)
+}
+
+func (s *Server) handleRegionStats(w http.ResponseWriter, r *http.Request, h *history.Store) {
+	if h == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "history is disabled"})
+		return
+	}
+	since, err := historySince(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	stats, err := h.RegionStats(r.URL.Query().Get("version"), since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("WARN: Failed to write API response: %v", err)
+	}
+}
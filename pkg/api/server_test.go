@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/models"
+)
+
+// fakeBackend 在 release 被关闭前保持“测试运行中”
+type fakeBackend struct {
+	mu      sync.Mutex
+	running bool
+	release chan struct{}
+}
+
+func (b *fakeBackend) Status() Status {
+	return Status{
+		State: "idle",
+		LastResults: map[string]VersionResult{
+			"v4": {Version: "v4", Success: true, FinishedAt: time.Now(), Results: []models.DeviceResult{{IP: "1.1.1.1"}}},
+		},
+	}
+}
+
+func (b *fakeBackend) TriggerRun() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.running {
+		return false
+	}
+	b.running = true
+	go func() {
+		<-b.release
+		b.mu.Lock()
+		b.running = false
+		b.mu.Unlock()
+	}()
+	return true
+}
+
+func newTestServer(t *testing.T, token string) (*httptest.Server, *fakeBackend) {
+	t.Helper()
+	b := &fakeBackend{release: make(chan struct{})}
+	s := NewServer(b, token)
+	s.Handle("GET /metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "cfst_client_runs_total 1\n")
+	}))
+	srv := httptest.NewServer(s.mux)
+	t.Cleanup(func() {
+		srv.Close()
+		select {
+		case <-b.release:
+		default:
+			close(b.release)
+		}
+	})
+	return srv, b
+}
+
+func do(t *testing.T, method, url, token string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAuth(t *testing.T) {
+	srv, _ := newTestServer(t, "s3cret")
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"status without token", http.MethodGet, "/api/status", "", http.StatusUnauthorized},
+		{"status with wrong token", http.MethodGet, "/api/status", "wrong", http.StatusUnauthorized},
+		{"status with token", http.MethodGet, "/api/status", "s3cret", http.StatusOK},
+		{"run without token", http.MethodPost, "/api/run", "", http.StatusUnauthorized},
+		{"metrics without token", http.MethodGet, "/metrics", "", http.StatusUnauthorized},
+		{"metrics with token", http.MethodGet, "/metrics", "s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(t, tt.method, srv.URL+tt.path, tt.token)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	open, _ := newTestServer(t, "")
+	if resp := do(t, http.MethodGet, open.URL+"/api/status", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("status without configured token = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestResultsByVersion(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+
+	resp := do(t, http.MethodGet, srv.URL+"/api/results/v4", "")
+	var res VersionResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || res.Version != "v4" || len(res.Results) != 1 {
+		t.Errorf("GET /api/results/v4 = %d %+v", resp.StatusCode, res)
+	}
+
+	resp = do(t, http.MethodGet, srv.URL+"/api/results/v6", "")
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusNotFound || body["error"] != "no results for v6" {
+		t.Errorf("GET /api/results/v6 = %d %v, want 404", resp.StatusCode, body)
+	}
+}
+
+func TestRunConflictsWhileInProgress(t *testing.T) {
+	srv, b := newTestServer(t, "")
+
+	if resp := do(t, http.MethodPost, srv.URL+"/api/run", ""); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("first POST /api/run = %d, want 202", resp.StatusCode)
+	}
+	if resp := do(t, http.MethodPost, srv.URL+"/api/run", ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("POST /api/run while running = %d, want 409", resp.StatusCode)
+	}
+	if resp := do(t, http.MethodGet, srv.URL+"/api/run", ""); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /api/run = %d, want 405", resp.StatusCode)
+	}
+
+	close(b.release)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp := do(t, http.MethodPost, srv.URL+"/api/run", "")
+		if resp.StatusCode == http.StatusAccepted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("POST /api/run after the run finished = %d, want 202", resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
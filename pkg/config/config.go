@@ -10,14 +10,47 @@ import (
 // ... (其他结构体不变) ...
 
 type CfConfig struct {
-	Binary     string   `yaml:"binary"`
-	Args       []string `yaml:"args"`
-	OutputFile string   `yaml:"output_file"`
+	// [新增] 测速引擎：cfst（默认，调用外部 CloudflareSpeedTest）或 native（内置 Go 实现）
+	Engine     string       `yaml:"engine"`
+	Binary     string       `yaml:"binary"`
+	Args       []string     `yaml:"args"`
+	OutputFile string       `yaml:"output_file"`
+	Native     NativeConfig `yaml:"native"`
+}
+
+// NativeConfig 是内置 Go 测速引擎的配置，零值字段使用默认值
+type NativeConfig struct {
+	URL           string `yaml:"url"`            // 下载测速地址
+	Port          int    `yaml:"port"`           // TCP 延迟测试端口
+	PingTimes     int    `yaml:"ping_times"`     // 每个 IP 的延迟测试次数
+	Concurrency   int    `yaml:"concurrency"`    // 延迟测试并发数
+	TimeoutMs     int    `yaml:"timeout_ms"`     // 单次 TCP 连接超时（毫秒）
+	MaxLatencyMs  int    `yaml:"max_latency_ms"` // 平均延迟上限（毫秒），超过则丢弃
+	DownloadCount int    `yaml:"download_count"` // 参与下载测速的 IP 数量
+	DownloadTime  int    `yaml:"download_time"`  // 单个 IP 的下载测速时长（秒）
+	IPv6Samples   int    `yaml:"ipv6_samples"`   // 每个 IPv6 网段随机抽取的 IP 数量
 }
 
 type UpdateConfig struct {
 	Check  bool   `yaml:"check"`
 	ApiURL string `yaml:"api_url"`
+	// [新增] 更新策略
+	Pin              string `yaml:"pin"`                // 固定安装的版本标签，如 v2.2.5，设置后忽略 policy 且允许降级
+	Policy           string `yaml:"policy"`             // any、minor（不跨主版本）或 patch（不跨次版本），默认 any
+	Prerelease       bool   `yaml:"prerelease"`         // 是否允许安装预发布版本
+	MinIntervalHours int    `yaml:"min_interval_hours"` // 两次检查之间的最小间隔（小时），0 表示每次都检查
+	// [新增] 压缩包中 ip.txt/ipv6.txt 的处理方式：always、never、only-if-missing（默认）或 merge
+	IPList string `yaml:"ip_list"`
+	// [新增] 下载文件的完整性校验
+	Verify UpdateVerifyConfig `yaml:"verify"`
+}
+
+// UpdateVerifyConfig 控制 CloudflareSpeedTest 压缩包的校验方式
+type UpdateVerifyConfig struct {
+	SHA256        []string `yaml:"sha256"`         // 固定的 SHA-256 列表，压缩包必须匹配其中之一
+	ChecksumAsset string   `yaml:"checksum_asset"` // Release 中的校验文件名，默认自动查找名称包含 checksum 或 sha256 的文件
+	PublicKey     string   `yaml:"public_key"`     // Base64 编码的 ed25519 公钥，设置后校验文件必须带有有效的 .sig 签名
+	Require       bool     `yaml:"require"`        // 无法获得任何校验值时拒绝安装
 }
 
 // [新增] 延迟重试的配置结构体
@@ -30,13 +63,20 @@ type TestOptions struct {
 	MinResults      int `yaml:"min_results"`
 	MaxRetries      int `yaml:"max_retries"`
 	GistUploadLimit int `yaml:"gist_upload_limit"`
-	RetryDelay      int `yaml:"retry_delay"` 
+	RetryDelay      int `yaml:"retry_delay"`
+	// [新增] 上传结果时包含扩展字段（schema_version 2），默认按旧格式上传
+	ExtendedResults bool `yaml:"extended_results"`
+	// [新增] 超时设置（分钟），超时后终止测速进程
+	AttemptTimeoutMinutes int `yaml:"attempt_timeout_minutes"` // 单次测速的超时，默认 30
+	RunTimeoutMinutes     int `yaml:"run_timeout_minutes"`     // 一轮完整测试（含重试和 IPv6）的超时，默认 120
 	// [新增] 嵌入延迟重试的配置
-	DelayedRetry    DelayedRetryConfig `yaml:"delayed_retry"`
+	DelayedRetry DelayedRetryConfig `yaml:"delayed_retry"`
 }
 
 // ... (其他结构体不变) ...
-type TelegramProxyConfig struct {
+
+// ProxyConfig 是通知渠道共用的代理配置，type 为 socks5 或 reverse_proxy
+type ProxyConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Type    string `yaml:"type"`
 	Address string `yaml:"address"`
@@ -44,17 +84,164 @@ type TelegramProxyConfig struct {
 }
 
 type TelegramConfig struct {
-	BotToken string              `yaml:"bot_token"`
-	ChatID   string              `yaml:"chat_id"`
-	Proxy    TelegramProxyConfig `yaml:"proxy"`
+	BotToken string      `yaml:"bot_token" secret:"true"`
+	ChatID   string      `yaml:"chat_id"`
+	Proxy    ProxyConfig `yaml:"proxy"`
+	// [新增] 启用后机器人会接收 chat_id 发来的 /run、/status 等命令；机器人只在启动时创建，修改后需重启
+	Commands bool `yaml:"commands"`
+}
+
+// PushPlusConfig 是 PushPlus 推送的配置
+type PushPlusConfig struct {
+	Token    string      `yaml:"token" secret:"true"`
+	Template string      `yaml:"template"` // html, markdown 或 txt，默认 html
+	Topic    string      `yaml:"topic"`    // 群组编码，为空时只推送给自己
+	APIURL   string      `yaml:"api_url"`  // 默认 https://www.pushplus.plus
+	Proxy    ProxyConfig `yaml:"proxy"`
+}
+
+// NotificationEventsConfig 控制各类运行事件是否发送通知
+type NotificationEventsConfig struct {
+	Success       bool `yaml:"success"`        // 测速成功并上传结果
+	Failure       bool `yaml:"failure"`        // 所有即时重试均失败
+	DelayedRetry  bool `yaml:"delayed_retry"`  // 已安排延迟重试
+	UploadFailure bool `yaml:"upload_failure"` // 结果上传失败
+	Update        bool `yaml:"update"`         // CloudflareSpeedTest 更新结果
+	Shutdown      bool `yaml:"shutdown"`       // 守护进程收到退出信号
 }
 
 type NotificationsConfig struct {
 	Enabled  bool           `yaml:"enabled"`
-	PushPlus struct {
-		Token string `yaml:"token"`
-	} `yaml:"pushplus"`
+	PushPlus PushPlusConfig `yaml:"pushplus"`
 	Telegram TelegramConfig `yaml:"telegram"`
+	// [新增] 国内常用的推送渠道，填写对应的 key/token 即启用
+	Bark       BarkConfig       `yaml:"bark"`
+	ServerChan ServerChanConfig `yaml:"serverchan"`
+	DingTalk   DingTalkConfig   `yaml:"dingtalk"`
+	WeCom      WeComConfig      `yaml:"wecom"`
+	Feishu     FeishuConfig     `yaml:"feishu"`
+	// [新增] 邮件通知
+	SMTP SMTPConfig `yaml:"smtp"`
+	// [新增] 自定义 Webhook，可配置多个
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+	// [新增] 成功通知中展示的最优 IP 数量
+	TopN   int                      `yaml:"top_n"`
+	Events NotificationEventsConfig `yaml:"events"`
+}
+
+// BarkConfig 是 Bark 推送的配置
+type BarkConfig struct {
+	DeviceKey string `yaml:"device_key" secret:"true"`
+	Group     string `yaml:"group"`
+	Sound     string `yaml:"sound"`
+	Icon      string `yaml:"icon"`
+	APIURL    string `yaml:"api_url"` // 默认 https://api.day.app，自建服务端时修改
+}
+
+// ServerChanConfig 是 Server酱 推送的配置
+type ServerChanConfig struct {
+	SendKey string `yaml:"send_key" secret:"true"`
+	APIURL  string `yaml:"api_url"` // 默认根据 SendKey 选择 Turbo 版或 Server酱³ 的地址
+}
+
+// DingTalkConfig 是钉钉自定义机器人的配置
+type DingTalkConfig struct {
+	AccessToken string `yaml:"access_token" secret:"true"`
+	Secret      string `yaml:"secret" secret:"true"` // 加签密钥，未开启加签时留空
+	APIURL      string `yaml:"api_url"`              // 默认 https://oapi.dingtalk.com
+}
+
+// WeComConfig 是企业微信群机器人的配置
+type WeComConfig struct {
+	Key    string `yaml:"key" secret:"true"`
+	APIURL string `yaml:"api_url"` // 默认 https://qyapi.weixin.qq.com
+}
+
+// FeishuConfig 是飞书自定义机器人的配置
+type FeishuConfig struct {
+	Token  string `yaml:"token" secret:"true"`  // Webhook 地址中 hook/ 之后的部分
+	Secret string `yaml:"secret" secret:"true"` // 签名校验密钥，未开启签名校验时留空
+	APIURL string `yaml:"api_url"`              // 默认 https://open.feishu.cn，Lark 可改为 https://open.larksuite.com
+}
+
+// SMTPConfig 是邮件通知的配置
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`     // 默认 starttls 为 587，tls 为 465
+	Security string   `yaml:"security"` // starttls、tls（隐式 TLS）或 none，默认 starttls
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password" secret:"true"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// WebhookConfig 描述一个自定义 Webhook 通知目标
+type WebhookConfig struct {
+	Name            string            `yaml:"name"`
+	URL             string            `yaml:"url" secret:"true"`
+	Method          string            `yaml:"method"`   // 默认 POST
+	Format          string            `yaml:"format"`   // json 或 form，默认 json
+	Template        string            `yaml:"template"` // Go text/template，为空时使用内置模板
+	Headers         map[string]string `yaml:"headers" secret:"true"`
+	Secret          string            `yaml:"secret" secret:"true"` // 非空时对请求体进行 HMAC-SHA256 签名
+	SignatureHeader string            `yaml:"signature_header"`     // 默认 X-Signature
+	MaxRetries      *int              `yaml:"max_retries"`          // 5xx 或网络错误时的重试次数，未设置时为 3，0 表示不重试
+}
+
+// StorageConfig 描述一个结果存储目标，不同类型使用不同的字段
+type StorageConfig struct {
+	Type string `yaml:"type"` // gist, local, s3, webdav
+	Name string `yaml:"name"` // 可选，用于日志和通知中区分多个同类型目标
+
+	// local: 本地目录，相对路径基于配置目录
+	Path string `yaml:"path"`
+
+	// s3: S3 兼容存储（如 AWS S3、MinIO、R2）
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"`
+	AccessKey string `yaml:"access_key" secret:"true"`
+	SecretKey string `yaml:"secret_key" secret:"true"`
+	PathStyle bool   `yaml:"path_style"`
+
+	// webdav: 目标目录地址及认证信息
+	URL      string `yaml:"url" secret:"true"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" secret:"true"`
+}
+
+// APIConfig 是内置 HTTP 状态接口的配置
+type APIConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`              // 监听地址，默认 ":8080"
+	Token   string `yaml:"token" secret:"true"` // Bearer Token，为空时不启用认证
+}
+
+// MetricsConfig 是 Prometheus 指标接口的配置
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"` // 留空时与 api 共用监听地址
+}
+
+// DNSConfig 是 Cloudflare DNS 自动更新的配置
+type DNSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	APIURL   string `yaml:"api_url"`                 // 默认 https://api.cloudflare.com/client/v4
+	APIToken string `yaml:"api_token" secret:"true"` // 需要 Zone.DNS 编辑权限
+	ZoneID   string `yaml:"zone_id"`
+	Name     string `yaml:"name"`    // 完整记录名，如 cdn.example.com
+	TopN     int    `yaml:"top_n"`   // 写入的最优 IP 数量，默认 1
+	TTL      int    `yaml:"ttl"`     // 1 表示自动，Proxied 为 true 时忽略
+	Proxied  bool   `yaml:"proxied"` // 是否开启 Cloudflare 代理
+}
+
+// HistoryConfig 是本地历史数据库的配置
+type HistoryConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	File          string `yaml:"file"`           // 数据库文件，相对路径基于配置目录
+	RetentionDays int    `yaml:"retention_days"` // 保留天数，0 表示不按时间清理
+	MaxRecords    int    `yaml:"max_records"`    // 最多保留的记录条数，0 表示不限制
 }
 
 // Config 是整个应用的配置结构
@@ -64,9 +251,11 @@ type Config struct {
 	TestIPv6     bool   `yaml:"test_ipv6"`
 	ProxyPrefix  string `yaml:"proxy_prefix"`
 	Cron         string `yaml:"cron"`
+	// [新增] 收到退出信号后完成全部清理的总时间（秒）：前一半等待当前测试完成，超时后终止测试，默认 8
+	ShutdownGraceSeconds int `yaml:"shutdown_grace_seconds"`
 
 	Gist struct {
-		Token  string `yaml:"token"`
+		Token  string `yaml:"token" secret:"true"`
 		GistID string `yaml:"gist_id"`
 	} `yaml:"gist"`
 
@@ -75,15 +264,35 @@ type Config struct {
 	Cf            CfConfig            `yaml:"cf"`
 	Cf6           CfConfig            `yaml:"cf6"`
 	Update        UpdateConfig        `yaml:"update"`
+	// [新增] 结果存储目标列表，为空时默认只上传到 Gist
+	Storage []StorageConfig `yaml:"storage"`
+	API     APIConfig       `yaml:"api"`
+	Metrics MetricsConfig   `yaml:"metrics"`
+	DNS     DNSConfig       `yaml:"dns"`
+	History HistoryConfig   `yaml:"history"`
 }
 
-// Load 读取并解析配置文件
+// Load 读取、解析并校验配置文件
 func Load(path string) (*Config, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 	var cfg Config
+	// 未在配置文件中出现的事件开关默认开启
+	cfg.Notifications.Events = NotificationEventsConfig{
+		Success:       true,
+		Failure:       true,
+		DelayedRetry:  true,
+		UploadFailure: true,
+		Update:        true,
+		Shutdown:      true,
+	}
+	cfg.History = HistoryConfig{
+		Enabled:       true,
+		File:          "history.jsonl",
+		RetentionDays: 30,
+	}
 	if err := yaml.Unmarshal(b, &cfg); err != nil {
 		return nil, err
 	}
@@ -93,10 +302,68 @@ func Load(path string) (*Config, error) {
 	cfg.Gist.Token = os.ExpandEnv(cfg.Gist.Token)
 	cfg.Notifications.Telegram.BotToken = os.ExpandEnv(cfg.Notifications.Telegram.BotToken)
 	cfg.Notifications.Telegram.ChatID = os.ExpandEnv(cfg.Notifications.Telegram.ChatID)
+	cfg.Notifications.PushPlus.Token = os.ExpandEnv(cfg.Notifications.PushPlus.Token)
+	n := &cfg.Notifications
+	n.Bark.DeviceKey = os.ExpandEnv(n.Bark.DeviceKey)
+	n.ServerChan.SendKey = os.ExpandEnv(n.ServerChan.SendKey)
+	n.DingTalk.AccessToken = os.ExpandEnv(n.DingTalk.AccessToken)
+	n.DingTalk.Secret = os.ExpandEnv(n.DingTalk.Secret)
+	n.WeCom.Key = os.ExpandEnv(n.WeCom.Key)
+	n.Feishu.Token = os.ExpandEnv(n.Feishu.Token)
+	n.Feishu.Secret = os.ExpandEnv(n.Feishu.Secret)
+	n.SMTP.Username = os.ExpandEnv(n.SMTP.Username)
+	n.SMTP.Password = os.ExpandEnv(n.SMTP.Password)
+	for i := range cfg.Notifications.Webhooks {
+		wh := &cfg.Notifications.Webhooks[i]
+		wh.URL = os.ExpandEnv(wh.URL)
+		wh.Secret = os.ExpandEnv(wh.Secret)
+		for k, v := range wh.Headers {
+			wh.Headers[k] = os.ExpandEnv(v)
+		}
+	}
+
+	cfg.API.Token = os.ExpandEnv(cfg.API.Token)
+	cfg.DNS.APIToken = os.ExpandEnv(cfg.DNS.APIToken)
+	cfg.DNS.ZoneID = os.ExpandEnv(cfg.DNS.ZoneID)
+	if cfg.API.Listen == "" {
+		cfg.API.Listen = ":8080"
+	}
+	if cfg.Metrics.Listen == "" {
+		cfg.Metrics.Listen = cfg.API.Listen
+	}
+	for i := range cfg.Storage {
+		st := &cfg.Storage[i]
+		st.Endpoint = os.ExpandEnv(st.Endpoint)
+		st.AccessKey = os.ExpandEnv(st.AccessKey)
+		st.SecretKey = os.ExpandEnv(st.SecretKey)
+		st.URL = os.ExpandEnv(st.URL)
+		st.Username = os.ExpandEnv(st.Username)
+		st.Password = os.ExpandEnv(st.Password)
+	}
+	if len(cfg.Storage) == 0 {
+		cfg.Storage = []StorageConfig{{Type: "gist"}}
+	}
 
 	if cfg.TestOptions.RetryDelay <= 0 {
 		cfg.TestOptions.RetryDelay = 5 // 默认为 5 秒
 	}
+	if cfg.ShutdownGraceSeconds == 0 {
+		cfg.ShutdownGraceSeconds = 8 // docker stop 默认 10 秒后强制终止
+	}
+	if cfg.TestOptions.AttemptTimeoutMinutes == 0 {
+		cfg.TestOptions.AttemptTimeoutMinutes = 30
+	}
+	if cfg.TestOptions.RunTimeoutMinutes == 0 {
+		cfg.TestOptions.RunTimeoutMinutes = 120
+	}
+	if cfg.Notifications.TopN <= 0 {
+		cfg.Notifications.TopN = 5
+	}
+
+	// [新增] 严格校验配置，一次性报告所有问题
+	if err := cfg.Validate(b); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
@@ -0,0 +1,74 @@
+// File: pkg/config/diff.go
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Diff 返回两份配置之间发生变化的字段，格式为 "path: old -> new"。
+// 带有 secret:"true" 标签的字段和所有 map（如 webhook 请求头）只显示是否变化，不输出具体值。
+func Diff(old, new *Config) []string {
+	var changes []string
+	diffValue("", reflect.ValueOf(*old), reflect.ValueOf(*new), false, &changes)
+	return changes
+}
+
+func diffValue(path string, a, b reflect.Value, secret bool, changes *[]string) {
+	switch a.Kind() {
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				name = strings.ToLower(t.Field(i).Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValue(name, a.Field(i), b.Field(i), secret || t.Field(i).Tag.Get("secret") == "true", changes)
+		}
+	case reflect.Slice:
+		if a.Type().Elem().Kind() != reflect.Struct {
+			if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+				*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", path, format(a, secret), format(b, secret)))
+			}
+			return
+		}
+		if a.Len() != b.Len() {
+			// 结构体列表可能包含密钥，只输出条目数量的变化
+			*changes = append(*changes, fmt.Sprintf("%s: %d entries -> %d entries", path, a.Len(), b.Len()))
+			return
+		}
+		for i := 0; i < a.Len(); i++ {
+			diffValue(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i), secret, changes)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			// map 的键值（如 Authorization 请求头）可能包含密钥，始终隐藏
+			secret = secret || a.Kind() == reflect.Map
+			*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", path, format(a, secret), format(b, secret)))
+		}
+	}
+}
+
+func format(v reflect.Value, secret bool) string {
+	if secret {
+		if v.IsZero() || (v.Kind() == reflect.Map && v.Len() == 0) {
+			return "<empty>"
+		}
+		return "<redacted>"
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "<unset>"
+		}
+		return format(v.Elem(), secret)
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	old := &Config{}
+	old.Notifications.Bark.DeviceKey = "bark-old"
+	old.Notifications.ServerChan.SendKey = "sct-old"
+	old.Notifications.WeCom.Key = "wecom-old"
+	old.Notifications.Webhooks = []WebhookConfig{{
+		URL:     "https://example.com/hook?token=old",
+		Headers: map[string]string{"Authorization": "Bearer old"},
+	}}
+	old.Cron = "0 0 * * *"
+
+	cur := &Config{}
+	cur.Notifications.Bark.DeviceKey = "bark-new"
+	cur.Notifications.ServerChan.SendKey = "sct-new"
+	cur.Notifications.WeCom.Key = "wecom-new"
+	cur.Notifications.Webhooks = []WebhookConfig{{
+		URL:     "https://example.com/hook?token=new",
+		Headers: map[string]string{"Authorization": "Bearer new"},
+	}}
+	cur.Cron = "*/5 * * * *"
+
+	changes := Diff(old, cur)
+	joined := strings.Join(changes, "\n")
+	for _, leaked := range []string{"bark-", "sct-", "wecom-", "token=", "Bearer"} {
+		if strings.Contains(joined, leaked) {
+			t.Errorf("diff leaks %q:\n%s", leaked, joined)
+		}
+	}
+	for _, want := range []string{
+		"notifications.bark.device_key: <redacted> -> <redacted>",
+		"notifications.webhooks[0].url: <redacted> -> <redacted>",
+		"notifications.webhooks[0].headers: <redacted> -> <redacted>",
+		`cron: "0 0 * * *" -> "*/5 * * * *"`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("diff is missing %q:\n%s", want, joined)
+		}
+	}
+}
+
+func TestDiffShowsWhenSecretIsSet(t *testing.T) {
+	old, cur := &Config{}, &Config{}
+	cur.API.Token = "s3cret"
+	changes := Diff(old, cur)
+	if len(changes) != 1 || changes[0] != "api.token: <empty> -> <redacted>" {
+		t.Fatalf("unexpected diff: %v", changes)
+	}
+}
@@ -0,0 +1,396 @@
+// File: pkg/config/validate.go
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// Problem 是配置中的一个错误
+type Problem struct {
+	Line    int    // YAML 中的行号，未知时为 0
+	Field   string // 以点号分隔的字段路径，如 test_options.max_retries
+	Message string
+}
+
+func (p Problem) String() string {
+	msg := p.Message
+	if p.Field != "" {
+		msg = p.Field + ": " + msg
+	}
+	if p.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", p.Line, msg)
+	}
+	return msg
+}
+
+// ValidationError 汇总了配置中的所有错误
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("config has %d problem(s):", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, "  - "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	gistIDPattern       = regexp.MustCompile(`^[0-9a-fA-F]{20,32}$`)
+	sha256Pattern       = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+	strictErrorLineExpr = regexp.MustCompile(`^line (\d+): (.*)$`)
+)
+
+// checkUnknownKeys 使用严格模式解析原始 YAML，报告拼写错误或不存在的字段
+func checkUnknownKeys(raw []byte) []Problem {
+	var probe Config
+	err := yaml.UnmarshalStrict(raw, &probe)
+	if err == nil {
+		return nil
+	}
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return []Problem{{Message: err.Error()}}
+	}
+	var problems []Problem
+	for _, msg := range typeErr.Errors {
+		p := Problem{Message: msg}
+		if m := strictErrorLineExpr.FindStringSubmatch(msg); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+			p.Message = m[2]
+		}
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// validator 收集问题并根据字段路径查找行号
+type validator struct {
+	lines    map[string]int
+	problems []Problem
+}
+
+func (v *validator) addf(field, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{
+		Line:    v.lineOf(field),
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// lineOf 返回字段所在行，字段不存在时回退到最近的父字段
+func (v *validator) lineOf(field string) int {
+	for field != "" {
+		if line, ok := v.lines[field]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(field, ".[")
+		if i < 0 {
+			break
+		}
+		field = field[:i]
+	}
+	return 0
+}
+
+// Validate 检查配置的取值是否合理，raw 为原始 YAML，用于定位行号。
+// 所有问题会一次性通过 *ValidationError 返回。
+func (c *Config) Validate(raw []byte) error {
+	v := &validator{lines: indexKeyLines(raw)}
+	v.problems = append(v.problems, checkUnknownKeys(raw)...)
+
+	if c.DeviceName == "" {
+		v.addf("device_name", "must not be empty")
+	}
+	if c.LineOperator == "" {
+		v.addf("line_operator", "must not be empty")
+	}
+	if c.Cron != "" {
+		if _, err := cron.ParseStandard(c.Cron); err != nil {
+			v.addf("cron", "invalid cron expression %q: %v", c.Cron, err)
+		}
+	}
+
+	if c.ShutdownGraceSeconds < 0 {
+		v.addf("shutdown_grace_seconds", "must not be negative, got %d", c.ShutdownGraceSeconds)
+	}
+
+	to := c.TestOptions
+	if to.MaxRetries < 1 {
+		v.addf("test_options.max_retries", "must be at least 1, got %d", to.MaxRetries)
+	}
+	if to.MinResults < 1 {
+		v.addf("test_options.min_results", "must be at least 1, got %d", to.MinResults)
+	}
+	if to.AttemptTimeoutMinutes < 0 {
+		v.addf("test_options.attempt_timeout_minutes", "must not be negative, got %d", to.AttemptTimeoutMinutes)
+	}
+	if to.RunTimeoutMinutes < 0 {
+		v.addf("test_options.run_timeout_minutes", "must not be negative, got %d", to.RunTimeoutMinutes)
+	}
+	if to.GistUploadLimit < 1 {
+		v.addf("test_options.gist_upload_limit", "must be at least 1, got %d", to.GistUploadLimit)
+	}
+	if to.DelayedRetry.Enabled && to.DelayedRetry.DelayMinutes < 1 {
+		v.addf("test_options.delayed_retry.delay_minutes", "must be at least 1 when delayed retry is enabled, got %d", to.DelayedRetry.DelayMinutes)
+	}
+
+	v.checkCf("cf", c.Cf, c.Update.Check)
+	if c.TestIPv6 {
+		// 更新器只会安装 cf.binary，cf6 使用同一路径时同样可以自动安装
+		v.checkCf("cf6", c.Cf6, c.Update.Check && c.Cf6.Binary == c.Cf.Binary)
+	}
+
+	if c.Update.Check && c.Update.ApiURL == "" {
+		v.addf("update.api_url", "must be set when update.check is enabled")
+	}
+	switch c.Update.Policy {
+	case "", "any", "minor", "patch":
+	default:
+		v.addf("update.policy", "must be any, minor or patch, got %q", c.Update.Policy)
+	}
+	if c.Update.Pin != "" && !strings.HasSuffix(strings.TrimRight(c.Update.ApiURL, "/"), "/releases/latest") {
+		v.addf("update.pin", "requires update.api_url to end with /releases/latest so the tag URL can be derived")
+	}
+	switch c.Update.IPList {
+	case "", "always", "never", "only-if-missing", "merge":
+	default:
+		v.addf("update.ip_list", "must be always, never, only-if-missing or merge, got %q", c.Update.IPList)
+	}
+	if c.Update.MinIntervalHours < 0 {
+		v.addf("update.min_interval_hours", "must not be negative, got %d", c.Update.MinIntervalHours)
+	}
+	for i, sum := range c.Update.Verify.SHA256 {
+		if !sha256Pattern.MatchString(sum) {
+			v.addf(fmt.Sprintf("update.verify.sha256[%d]", i), "must be a 64 character hexadecimal SHA-256 hash, got %q", sum)
+		}
+	}
+	if pk := c.Update.Verify.PublicKey; pk != "" {
+		if key, err := base64.StdEncoding.DecodeString(pk); err != nil || len(key) != ed25519.PublicKeySize {
+			v.addf("update.verify.public_key", "must be a base64 encoded %d byte ed25519 public key", ed25519.PublicKeySize)
+		}
+	}
+
+	v.checkProxy("notifications.telegram.proxy", c.Notifications.Telegram.Proxy)
+	pp := c.Notifications.PushPlus
+	switch pp.Template {
+	case "", "html", "markdown", "txt":
+	default:
+		v.addf("notifications.pushplus.template", "must be html, markdown or txt, got %q", pp.Template)
+	}
+	v.checkProxy("notifications.pushplus.proxy", pp.Proxy)
+
+	if sm := c.Notifications.SMTP; sm.Host != "" {
+		if sm.From == "" {
+			v.addf("notifications.smtp.from", "must be set when smtp.host is set")
+		}
+		if len(sm.To) == 0 {
+			v.addf("notifications.smtp.to", "must list at least one recipient")
+		}
+		switch sm.Security {
+		case "", "starttls", "tls", "none":
+		default:
+			v.addf("notifications.smtp.security", "must be starttls, tls or none, got %q", sm.Security)
+		}
+	}
+
+	for i, wh := range c.Notifications.Webhooks {
+		field := fmt.Sprintf("notifications.webhooks[%d]", i)
+		if wh.URL == "" {
+			v.addf(field+".url", "must not be empty")
+		}
+		switch wh.Format {
+		case "", "json", "form":
+		default:
+			v.addf(field+".format", "must be json or form, got %q", wh.Format)
+		}
+		if wh.Template != "" {
+			// 只检查语法，函数的实现由 notifier 包提供
+			funcs := template.FuncMap{"json": func(interface{}) (string, error) { return "", nil }}
+			if _, err := template.New("webhook").Funcs(funcs).Parse(wh.Template); err != nil {
+				v.addf(field+".template", "invalid template: %v", err)
+			}
+		}
+		if wh.MaxRetries != nil && *wh.MaxRetries < 0 {
+			v.addf(field+".max_retries", "must not be negative, got %d", *wh.MaxRetries)
+		}
+	}
+
+	for i, sc := range c.Storage {
+		field := fmt.Sprintf("storage[%d]", i)
+		switch sc.Type {
+		case "gist":
+			if !gistIDPattern.MatchString(c.Gist.GistID) {
+				v.addf("gist.gist_id", "must be a 20-32 character hexadecimal Gist ID, got %q", c.Gist.GistID)
+			}
+		case "local":
+			if sc.Path == "" {
+				v.addf(field+".path", "must be set for local storage")
+			}
+		case "s3":
+			if sc.Endpoint == "" || sc.Bucket == "" {
+				v.addf(field, "endpoint and bucket must be set for s3 storage")
+			}
+		case "webdav":
+			if sc.URL == "" {
+				v.addf(field+".url", "must be set for webdav storage")
+			}
+		default:
+			v.addf(field+".type", "must be one of gist, local, s3, webdav, got %q", sc.Type)
+		}
+	}
+
+	if c.DNS.Enabled && (c.DNS.APIToken == "" || c.DNS.ZoneID == "" || c.DNS.Name == "") {
+		v.addf("dns", "api_token, zone_id and name must be set when dns is enabled")
+	}
+	if c.History.RetentionDays < 0 {
+		v.addf("history.retention_days", "must not be negative, got %d", c.History.RetentionDays)
+	}
+
+	if len(v.problems) > 0 {
+		// 按行号排序，无法定位行号的问题放在最后
+		sort.SliceStable(v.problems, func(i, j int) bool {
+			li, lj := v.problems[i].Line, v.problems[j].Line
+			return li != 0 && (lj == 0 || li < lj)
+		})
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// checkProxy 检查通知渠道的代理配置
+func (v *validator) checkProxy(field string, p ProxyConfig) {
+	if !p.Enabled {
+		return
+	}
+	switch p.Type {
+	case "socks5":
+		if p.Address == "" {
+			v.addf(field+".address", "must be set for socks5 proxy")
+		}
+	case "reverse_proxy":
+		if p.ApiURL == "" {
+			v.addf(field+".api_url", "must be set for reverse_proxy")
+		}
+	default:
+		v.addf(field+".type", "must be socks5 or reverse_proxy, got %q", p.Type)
+	}
+}
+
+// checkCf 检查测速引擎配置，canInstall 表示缺失的程序可由自动更新安装
+func (v *validator) checkCf(field string, cf CfConfig, canInstall bool) {
+	switch cf.Engine {
+	case "native":
+		return
+	case "", "cfst":
+	default:
+		v.addf(field+".engine", "must be cfst or native, got %q", cf.Engine)
+		return
+	}
+
+	if cf.OutputFile == "" {
+		v.addf(field+".output_file", "must not be empty")
+	}
+	if cf.Binary == "" {
+		v.addf(field+".binary", "must not be empty")
+		return
+	}
+	info, err := os.Stat(cf.Binary)
+	switch {
+	case os.IsNotExist(err):
+		if !canInstall {
+			v.addf(field+".binary", "%s does not exist (enable update.check to install it automatically)", cf.Binary)
+		}
+	case err != nil:
+		v.addf(field+".binary", "cannot access %s: %v", cf.Binary, err)
+	case info.IsDir():
+		v.addf(field+".binary", "%s is a directory", cf.Binary)
+	case runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0:
+		v.addf(field+".binary", "%s is not executable", cf.Binary)
+	}
+}
+
+// indexKeyLines 扫描块格式的 YAML，返回每个字段路径所在的行号。
+// 路径形如 test_options.delayed_retry.enabled 或 storage[1].type，
+// 流式写法（{...} / [...]）内部的字段不会被索引。
+func indexKeyLines(raw []byte) map[string]int {
+	type frame struct {
+		indent int
+		path   string
+	}
+	lines := make(map[string]int)
+	listIndex := make(map[string]int)
+	var stack []frame
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := scanner.Text()
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(text) - len(trimmed)
+
+		item := false
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			item = true
+			rest := strings.TrimLeft(strings.TrimPrefix(trimmed, "-"), " ")
+			indent += len(trimmed) - len(rest)
+			trimmed = rest
+		}
+
+		// 列表项的帧缩进比内容少 1，新的列表项需要同时弹出上一个列表项
+		threshold := indent
+		if item {
+			threshold = indent - 1
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= threshold {
+			stack = stack[:len(stack)-1]
+		}
+		parent := ""
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1].path
+		}
+		if item {
+			idx := listIndex[parent]
+			listIndex[parent] = idx + 1
+			parent = fmt.Sprintf("%s[%d]", parent, idx)
+			lines[parent] = lineNo
+			stack = append(stack, frame{indent: indent - 1, path: parent})
+		}
+
+		colon := strings.Index(trimmed, ":")
+		if colon <= 0 || strings.ContainsAny(trimmed[:colon], " \"'{[") {
+			continue
+		}
+		key := trimmed[:colon]
+		path := key
+		if parent != "" {
+			path = parent + "." + key
+		}
+		if _, seen := lines[path]; !seen {
+			lines[path] = lineNo
+		}
+		stack = append(stack, frame{indent: indent, path: path})
+	}
+	return lines
+}
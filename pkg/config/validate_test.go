@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// validBase 是一份能通过校验的最小配置，测试在其后追加或替换内容
+const validBase = `device_name: nas
+line_operator: ct
+cron: "0 */6 * * *"
+gist:
+  token: t
+  gist_id: 0123456789abcdef0123
+cf:
+  engine: native
+test_options:
+  min_results: 5
+  max_retries: 3
+  gist_upload_limit: 10
+`
+
+// loadProblems 写入并加载配置，返回校验发现的问题
+func loadProblems(t *testing.T, yml string) []Problem {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load returned %T (%v), want *ValidationError", err, err)
+	}
+	return verr.Problems
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	if problems := loadProblems(t, validBase); problems != nil {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+}
+
+func TestValidateReportsProblemsWithLines(t *testing.T) {
+	tests := []struct {
+		name string
+		yml  string
+		want Problem
+	}{
+		{
+			name: "unknown key",
+			yml:  validBase + "test_optoins:\n  max_retries: 3\n",
+			want: Problem{Line: 13, Message: "field test_optoins not found in type config.Config"},
+		},
+		{
+			name: "nested unknown key",
+			yml:  strings.Replace(validBase, "  max_retries: 3\n", "  max_retires: 3\n  max_retries: 3\n", 1),
+			want: Problem{Line: 11, Message: "field max_retires not found in type config.TestOptions"},
+		},
+		{
+			name: "bad cron expression",
+			yml:  strings.Replace(validBase, `cron: "0 */6 * * *"`, `cron: "every hour"`, 1),
+			want: Problem{Line: 3, Field: "cron"},
+		},
+		{
+			name: "out of range value",
+			yml:  strings.Replace(validBase, "max_retries: 3", "max_retries: 0", 1),
+			want: Problem{Line: 11, Field: "test_options.max_retries", Message: "must be at least 1, got 0"},
+		},
+		{
+			name: "negative value",
+			yml:  validBase + "shutdown_grace_seconds: -1\n",
+			want: Problem{Line: 13, Field: "shutdown_grace_seconds", Message: "must not be negative, got -1"},
+		},
+		{
+			name: "list item path",
+			yml: validBase + `storage:
+  - type: gist
+  - name: backup
+    type: ftp
+`,
+			want: Problem{Line: 16, Field: "storage[1].type", Message: `must be one of gist, local, s3, webdav, got "ftp"`},
+		},
+		{
+			name: "missing field in list item falls back to the item line",
+			yml: validBase + `storage:
+  - type: local
+`,
+			want: Problem{Line: 14, Field: "storage[0].path", Message: "must be set for local storage"},
+		},
+		{
+			name: "negative webhook retries",
+			yml: validBase + `notifications:
+  webhooks:
+    - url: https://example.com
+      max_retries: -1
+`,
+			want: Problem{Line: 16, Field: "notifications.webhooks[0].max_retries", Message: "must not be negative, got -1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := loadProblems(t, tt.yml)
+			if len(problems) != 1 {
+				t.Fatalf("problems = %v, want exactly one", problems)
+			}
+			got := problems[0]
+			if tt.want.Message == "" {
+				got.Message = "" // 只检查行号和字段，消息来自 cron 库
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problem = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllProblemsInLineOrder(t *testing.T) {
+	yml := `device_name: nas
+line_operator: ct
+gist:
+  gist_id: 0123456789abcdef0123
+cf:
+  engine: native
+storage:
+  - type: ftp
+test_options:
+  gist_upload_limit: 0
+  min_results: 5
+  max_retries: 0
+  colour: blue
+cron: "bad"
+dns:
+  enabled: true
+`
+	problems := loadProblems(t, yml)
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`line 8: storage[0].type: must be one of gist, local, s3, webdav, got "ftp"`,
+		"line 10: test_options.gist_upload_limit: must be at least 1, got 0",
+		"line 12: test_options.max_retries: must be at least 1, got 0",
+		"line 13: field colour not found in type config.TestOptions",
+		"line 14: cron: ",
+		"line 15: dns: api_token, zone_id and name must be set when dns is enabled",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("problems =\n%s\nwant %d problems", strings.Join(got, "\n"), len(want))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("problem %d = %q, want prefix %q", i, got[i], want[i])
+		}
+	}
+
+	err := (&ValidationError{Problems: problems}).Error()
+	if !strings.HasPrefix(err, "config has 6 problem(s):\n  - line 8: ") {
+		t.Errorf("Error() = %q", err)
+	}
+}
+
+func TestIndexKeyLines(t *testing.T) {
+	yml := `# comment
+a: 1
+b:
+  c: 2
+  list:
+    - x: 1
+      y: 2
+    -
+      x: 3
+  d: 4
+e: [1, 2]
+`
+	want := map[string]int{
+		"a": 2, "b": 3, "b.c": 4, "b.list": 5,
+		"b.list[0]": 6, "b.list[0].x": 6, "b.list[0].y": 7,
+		"b.list[1]": 8, "b.list[1].x": 9,
+		"b.d": 10, "e": 11,
+	}
+	if got := indexKeyLines([]byte(yml)); !reflect.DeepEqual(got, want) {
+		t.Errorf("indexKeyLines =\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+// File: pkg/dns/cloudflare.go
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+const defaultAPIURL = "https://api.cloudflare.com/client/v4"
+
+// Updater 使用 Cloudflare API 将最优 IP 写入 DNS 记录
+type Updater struct {
+	apiURL     string
+	token      string
+	zoneID     string
+	name       string
+	topN       int
+	ttl        int
+	proxied    bool
+	httpClient *http.Client
+}
+
+// record 是 Cloudflare API 中的一条 DNS 记录
+type record struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+// apiResponse 是 Cloudflare API 的通用响应结构
+type apiResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// NewUpdater 根据配置创建一个新的 DNS 更新器
+func NewUpdater(cfg config.DNSConfig) (*Updater, error) {
+	if cfg.APIToken == "" || cfg.ZoneID == "" || cfg.Name == "" {
+		return nil, fmt.Errorf("api_token, zone_id and name must be set")
+	}
+	apiURL := strings.TrimRight(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	topN := cfg.TopN
+	if topN <= 0 {
+		topN = 1
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 || cfg.Proxied {
+		// 1 表示由 Cloudflare 自动决定；开启代理的记录总是被 Cloudflare 保存为 1，
+		// 按配置的 TTL 比较会导致每次都重写记录
+		ttl = 1
+	}
+	return &Updater{
+		apiURL:  apiURL,
+		token:   cfg.APIToken,
+		zoneID:  cfg.ZoneID,
+		name:    cfg.Name,
+		topN:    topN,
+		ttl:     ttl,
+		proxied: cfg.Proxied,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+// Update 将已排序结果中的前 topN 个 IP 写入 A（v4）或 AAAA（v6）记录。
+// 记录已与期望一致时不做任何修改并返回 false。
+func (u *Updater) Update(version string, results []models.DeviceResult) (bool, error) {
+	recordType := "A"
+	if version == "v6" {
+		recordType = "AAAA"
+	}
+
+	var desired []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if len(desired) >= u.topN {
+			break
+		}
+		if !seen[r.IP] {
+			seen[r.IP] = true
+			desired = append(desired, r.IP)
+		}
+	}
+	if len(desired) == 0 {
+		return false, fmt.Errorf("no IPs to write")
+	}
+
+	existing, err := u.list(recordType)
+	if err != nil {
+		return false, err
+	}
+
+	// 先按内容匹配：已指向期望 IP 的记录保留，属性不一致时原地修正；
+	// 只有未匹配的旧记录才会被改写为缺失的 IP，避免改写出重复内容（Cloudflare 会以 81058 拒绝）
+	var fixes, leftovers []record
+	matched := make(map[string]bool)
+	for _, rec := range existing {
+		if !seen[rec.Content] || matched[rec.Content] {
+			leftovers = append(leftovers, rec)
+			continue
+		}
+		matched[rec.Content] = true
+		if rec.TTL != u.ttl || rec.Proxied != u.proxied {
+			fixes = append(fixes, rec)
+		}
+	}
+	var missing []string
+	for _, ip := range desired {
+		if !matched[ip] {
+			missing = append(missing, ip)
+		}
+	}
+	if len(fixes) == 0 && len(missing) == 0 && len(leftovers) == 0 {
+		log.Printf("DNS: %s record %s already points to %s, nothing to do.", recordType, u.name, strings.Join(desired, ", "))
+		return false, nil
+	}
+
+	for _, old := range fixes {
+		rec := record{ID: old.ID, Type: recordType, Name: u.name, Content: old.Content, TTL: u.ttl, Proxied: u.proxied}
+		log.Printf("DNS: Updating ttl/proxied of %s record %s -> %s", recordType, u.name, old.Content)
+		if err := u.do(http.MethodPut, "/dns_records/"+rec.ID, rec, nil); err != nil {
+			return true, err
+		}
+	}
+	for _, ip := range missing {
+		rec := record{Type: recordType, Name: u.name, Content: ip, TTL: u.ttl, Proxied: u.proxied}
+		if len(leftovers) > 0 {
+			rec.ID = leftovers[0].ID
+			leftovers = leftovers[1:]
+			log.Printf("DNS: Updating %s record %s -> %s", recordType, u.name, ip)
+			err = u.do(http.MethodPut, "/dns_records/"+rec.ID, rec, nil)
+		} else {
+			log.Printf("DNS: Creating %s record %s -> %s", recordType, u.name, ip)
+			err = u.do(http.MethodPost, "/dns_records", rec, nil)
+		}
+		if err != nil {
+			return true, err
+		}
+	}
+	for _, rec := range leftovers {
+		log.Printf("DNS: Deleting stale %s record %s -> %s", recordType, u.name, rec.Content)
+		if err := u.do(http.MethodDelete, "/dns_records/"+rec.ID, nil, nil); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// list 返回指定名称和类型的所有记录
+func (u *Updater) list(recordType string) ([]record, error) {
+	q := url.Values{}
+	q.Set("type", recordType)
+	q.Set("name", u.name)
+	q.Set("per_page", "100")
+	var records []record
+	if err := u.do(http.MethodGet, "/dns_records?"+q.Encode(), nil, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// do 发送 API 请求并解析 Cloudflare 的响应信封
+func (u *Updater) do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/zones/%s%s", u.apiURL, u.zoneID, path), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+u.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare api request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var ar apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ar); err != nil {
+		return fmt.Errorf("cloudflare api returned %s: %w", resp.Status, err)
+	}
+	if !ar.Success {
+		var msgs []string
+		for _, e := range ar.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+		return fmt.Errorf("cloudflare api %s %s failed (%s): %s", method, path, resp.Status, strings.Join(msgs, "; "))
+	}
+	if out != nil {
+		if err := json.Unmarshal(ar.Result, out); err != nil {
+			return fmt.Errorf("failed to decode cloudflare api result: %w", err)
+		}
+	}
+	return nil
+}
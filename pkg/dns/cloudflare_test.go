@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+// fakeZone 模拟 Cloudflare DNS API，按 ID 保存记录，并像真实 API 一样拒绝同名同类型的重复内容
+type fakeZone struct {
+	mu      sync.Mutex
+	order   []string
+	records map[string]record
+	calls   []string
+	nextID  int
+}
+
+func (z *fakeZone) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.calls = append(z.calls, r.Method+" "+r.URL.Path)
+
+	id := strings.TrimPrefix(r.URL.Path, "/zones/zone/dns_records")
+	id = strings.TrimPrefix(id, "/")
+	switch r.Method {
+	case http.MethodGet:
+		var list []record
+		for _, id := range z.order {
+			list = append(list, z.records[id])
+		}
+		writeResult(w, list)
+	case http.MethodPost, http.MethodPut:
+		var rec record
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for otherID, other := range z.records {
+			if otherID != id && other.Content == rec.Content {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"success":false,"errors":[{"code":81058,"message":"An identical record already exists."}]}`)
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			z.nextID++
+			id = fmt.Sprintf("new%d", z.nextID)
+			z.order = append(z.order, id)
+		}
+		rec.ID = id
+		z.records[id] = rec
+		writeResult(w, rec)
+	case http.MethodDelete:
+		delete(z.records, id)
+		for i, o := range z.order {
+			if o == id {
+				z.order = append(z.order[:i], z.order[i+1:]...)
+				break
+			}
+		}
+		writeResult(w, map[string]string{"id": id})
+	}
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	data, _ := json.Marshal(result)
+	fmt.Fprintf(w, `{"success":true,"errors":[],"result":%s}`, data)
+}
+
+func TestUpdateFixesMatchingRecordBeforeReusingStale(t *testing.T) {
+	zone := &fakeZone{
+		order: []string{"stale", "keep"},
+		records: map[string]record{
+			"stale": {ID: "stale", Type: "A", Name: "cf.example.com", Content: "9.9.9.9", TTL: 60},
+			"keep":  {ID: "keep", Type: "A", Name: "cf.example.com", Content: "1.1.1.1", TTL: 300, Proxied: true},
+		},
+	}
+	srv := httptest.NewServer(zone)
+	defer srv.Close()
+
+	u, err := NewUpdater(config.DNSConfig{APIURL: srv.URL, APIToken: "t", ZoneID: "zone", Name: "cf.example.com", TTL: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := u.Update("v4", []models.DeviceResult{{IP: "1.1.1.1"}})
+	if err != nil {
+		t.Fatalf("Update: %v (calls %v)", err, zone.calls)
+	}
+	if !changed {
+		t.Fatal("Update reported no change")
+	}
+
+	if len(zone.records) != 1 {
+		t.Fatalf("records = %+v, want only the fixed record", zone.records)
+	}
+	got, ok := zone.records["keep"]
+	if !ok || got.Content != "1.1.1.1" || got.TTL != 60 || got.Proxied {
+		t.Errorf("record keep = %+v, want 1.1.1.1 with ttl 60 and proxied false", got)
+	}
+
+	changed, err = u.Update("v4", []models.DeviceResult{{IP: "1.1.1.1"}})
+	if err != nil || changed {
+		t.Errorf("second Update = %v, %v, want no change", changed, err)
+	}
+}
+
+func TestUpdateReusesStaleRecordForMissingIP(t *testing.T) {
+	zone := &fakeZone{
+		order: []string{"a", "b"},
+		records: map[string]record{
+			"a": {ID: "a", Type: "A", Name: "cf.example.com", Content: "9.9.9.9", TTL: 1},
+			"b": {ID: "b", Type: "A", Name: "cf.example.com", Content: "1.1.1.1", TTL: 1},
+		},
+	}
+	srv := httptest.NewServer(zone)
+	defer srv.Close()
+
+	u, err := NewUpdater(config.DNSConfig{APIURL: srv.URL, APIToken: "t", ZoneID: "zone", Name: "cf.example.com", TopN: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Update("v4", []models.DeviceResult{{IP: "1.1.1.1"}, {IP: "2.2.2.2"}}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if zone.records["a"].Content != "2.2.2.2" || zone.records["b"].Content != "1.1.1.1" || len(zone.records) != 2 {
+		t.Errorf("records = %+v, want a -> 2.2.2.2 and b -> 1.1.1.1", zone.records)
+	}
+	for _, c := range zone.calls {
+		if strings.HasPrefix(c, http.MethodPost) || strings.HasPrefix(c, http.MethodDelete) {
+			t.Errorf("unexpected call %s", c)
+		}
+	}
+}
+
+func TestUpdateProxiedRecordIgnoresConfiguredTTL(t *testing.T) {
+	zone := &fakeZone{
+		order: []string{"a"},
+		records: map[string]record{
+			"a": {ID: "a", Type: "A", Name: "cf.example.com", Content: "1.1.1.1", TTL: 1, Proxied: true},
+		},
+	}
+	srv := httptest.NewServer(zone)
+	defer srv.Close()
+
+	u, err := NewUpdater(config.DNSConfig{APIURL: srv.URL, APIToken: "t", ZoneID: "zone", Name: "cf.example.com", TTL: 300, Proxied: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := u.Update("v4", []models.DeviceResult{{IP: "1.1.1.1"}})
+	if err != nil || changed {
+		t.Errorf("Update = %v, %v, want no change", changed, err)
+	}
+	for _, c := range zone.calls {
+		if !strings.HasPrefix(c, http.MethodGet) {
+			t.Errorf("unexpected call %s", c)
+		}
+	}
+}
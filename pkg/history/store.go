@@ -0,0 +1,324 @@
+// File: pkg/history/store.go
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"cfst-client/pkg/models"
+)
+
+// Record 是一次测速尝试的完整结果
+type Record struct {
+	Time     time.Time             `json:"time"`
+	Version  string                `json:"version"`
+	Attempt  int                   `json:"attempt"`
+	Device   string                `json:"device"`
+	Operator string                `json:"operator"`
+	Results  []models.DeviceResult `json:"results"`
+}
+
+// Point 是某个 IP 在某次测速中的表现
+type Point struct {
+	Time      time.Time `json:"time"`
+	Version   string    `json:"version"`
+	LatencyMs int       `json:"latency_ms"`
+	LossPct   float64   `json:"loss_pct"`
+	DLMBps    float64   `json:"dl_mbps"`
+	Region    string    `json:"region"`
+}
+
+// RegionStat 是某个地区码在一段时间内的聚合统计
+type RegionStat struct {
+	Region       string  `json:"region"`
+	Samples      int     `json:"samples"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MinLatencyMs int     `json:"min_latency_ms"`
+	AvgLossPct   float64 `json:"avg_loss_pct"`
+	AvgDLMBps    float64 `json:"avg_dl_mbps"`
+	MaxDLMBps    float64 `json:"max_dl_mbps"`
+}
+
+// Store 是基于 JSON Lines 文件的嵌入式历史数据库，每行保存一条 Record，
+// 新记录只追加写入，过期记录在 Prune 时通过重写文件清理。
+// [修改] 打开时将全部记录读入内存，查询不再读取文件；同一文件在进程内应只打开一个 Store。
+type Store struct {
+	mu         sync.Mutex
+	path       string
+	retention  time.Duration
+	maxRecords int
+	records    []Record // 与文件内容一致，按写入顺序排列
+	readOnly   bool
+}
+
+// errReadOnly 在只读打开的 Store 上写入时返回
+var errReadOnly = errors.New("history database is opened read-only")
+
+// Open 打开（必要时创建）位于 path 的历史数据库。
+// retention 为 0 时不按时间清理，maxRecords 为 0 时不限制条数。
+func Open(path string, retention time.Duration, maxRecords int) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	f.Close()
+	s := &Store{path: path, retention: retention, maxRecords: maxRecords}
+	if s.records, err = s.readAll(); err != nil {
+		return nil, fmt.Errorf("failed to read history database: %w", err)
+	}
+	return s, nil
+}
+
+// OpenReadOnly 以只读方式打开位于 path 的历史数据库，供查询命令使用。
+// 文件不存在时返回包装了 fs.ErrNotExist 的错误，不会创建文件或目录；返回的 Store 不能写入。
+func OpenReadOnly(path string) (*Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	s := &Store{path: path, readOnly: true}
+	var err error
+	if s.records, err = s.readAll(); err != nil {
+		return nil, fmt.Errorf("failed to read history database: %w", err)
+	}
+	return s, nil
+}
+
+// SetLimits 修改保留策略，在下一次 Prune 时生效
+func (s *Store) SetLimits(retention time.Duration, maxRecords int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention, s.maxRecords = retention, maxRecords
+}
+
+// Append 追加一条记录。记录的 Results 会被复制，调用方之后修改原切片不影响已保存的历史。
+func (s *Store) Append(rec Record) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	rec = rec.clone()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.records = append(s.records, rec)
+	return nil
+}
+
+// Prune 按保留策略删除过期记录，返回删除的条数
+func (s *Store) Prune() (int, error) {
+	if s.readOnly {
+		return 0, errReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.records
+	kept := records
+	if s.retention > 0 {
+		cutoff := time.Now().Add(-s.retention)
+		kept = kept[:0:0]
+		for _, r := range records {
+			if !r.Time.Before(cutoff) {
+				kept = append(kept, r)
+			}
+		}
+	}
+	if s.maxRecords > 0 && len(kept) > s.maxRecords {
+		kept = kept[len(kept)-s.maxRecords:]
+	}
+	removed := len(records) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range kept {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return 0, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return 0, err
+	}
+	s.records = kept
+	return removed, nil
+}
+
+// Records 返回 since 之后的所有记录，version 为空时不过滤 IP 版本。
+// 返回的记录是副本，调用方可以随意排序或修改。
+func (s *Store) Records(version string, since time.Time) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Record
+	for _, r := range s.records {
+		if r.Time.Before(since) || (version != "" && r.Version != version) {
+			continue
+		}
+		out = append(out, r.clone())
+	}
+	return out, nil
+}
+
+// clone 返回 Results 不与原记录共享底层数组的副本
+func (r Record) clone() Record {
+	if r.Results != nil {
+		r.Results = append([]models.DeviceResult(nil), r.Results...)
+	}
+	return r
+}
+
+// Latest 返回指定 IP 版本最近一次的记录，没有记录时返回 nil
+func (s *Store) Latest(version string) (*Record, error) {
+	records, err := s.Records(version, time.Time{})
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[len(records)-1], nil
+}
+
+// IPTrend 返回某个 IP 自 since 以来每次测速的表现，按时间排序
+func (s *Store) IPTrend(ip string, since time.Time) ([]Point, error) {
+	records, err := s.Records("", since)
+	if err != nil {
+		return nil, err
+	}
+	points := []Point{}
+	for _, rec := range records {
+		for _, r := range rec.Results {
+			if r.IP != ip {
+				continue
+			}
+			points = append(points, Point{
+				Time:      rec.Time,
+				Version:   rec.Version,
+				LatencyMs: r.LatencyMs,
+				LossPct:   r.LossPct,
+				DLMBps:    r.DLMBps,
+				Region:    r.Region,
+			})
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points, nil
+}
+
+// RegionStats 按地区码聚合 since 以来的所有结果，按平均延迟升序排列
+func (s *Store) RegionStats(version string, since time.Time) ([]RegionStat, error) {
+	records, err := s.Records(version, since)
+	if err != nil {
+		return nil, err
+	}
+	type acc struct {
+		stat       RegionStat
+		latencySum int
+		lossSum    float64
+		speedSum   float64
+	}
+	byRegion := make(map[string]*acc)
+	for _, rec := range records {
+		for _, r := range rec.Results {
+			region := r.Region
+			if region == "" {
+				region = "unknown"
+			}
+			a, ok := byRegion[region]
+			if !ok {
+				a = &acc{stat: RegionStat{Region: region, MinLatencyMs: r.LatencyMs}}
+				byRegion[region] = a
+			}
+			a.stat.Samples++
+			a.latencySum += r.LatencyMs
+			a.lossSum += r.LossPct
+			a.speedSum += r.DLMBps
+			if r.LatencyMs < a.stat.MinLatencyMs {
+				a.stat.MinLatencyMs = r.LatencyMs
+			}
+			if r.DLMBps > a.stat.MaxDLMBps {
+				a.stat.MaxDLMBps = r.DLMBps
+			}
+		}
+	}
+	stats := make([]RegionStat, 0, len(byRegion))
+	for _, a := range byRegion {
+		n := float64(a.stat.Samples)
+		a.stat.AvgLatencyMs = float64(a.latencySum) / n
+		a.stat.AvgLossPct = a.lossSum / n
+		a.stat.AvgDLMBps = a.speedSum / n
+		stats = append(stats, a.stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].AvgLatencyMs < stats[j].AvgLatencyMs })
+	return stats, nil
+}
+
+// readAll 从文件读取全部记录，只在打开时调用。无法解析的行会被跳过。
+func (s *Store) readAll() ([]Record, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			log.Printf("WARN: Skipping corrupt history record at %s:%d: %v", s.path, line, err)
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,137 @@
+package history
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/models"
+)
+
+func TestStoreKeepsRecordsInMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, v := range []string{"v4", "v6", "v4"} {
+		rec := Record{Time: now.Add(time.Duration(i) * time.Minute), Version: v, Attempt: i + 1,
+			Results: []models.DeviceResult{{IP: "1.1.1.1", LatencyMs: 10 + i}}}
+		if err := s.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := s.Latest("v4")
+	if err != nil || latest == nil || latest.Attempt != 3 {
+		t.Fatalf("Latest(v4) = %+v, %v", latest, err)
+	}
+
+	reopened, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := reopened.Records("", time.Time{})
+	if err != nil || len(records) != 3 {
+		t.Fatalf("reopened store has %d records (%v), want 3", len(records), err)
+	}
+}
+
+func TestStorePruneUsesUpdatedLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, age := range []time.Duration{72 * time.Hour, 2 * time.Hour, time.Hour, 0} {
+		if err := s.Append(Record{Time: now.Add(-age), Version: "v4"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := s.Prune(); err != nil || n != 0 {
+		t.Fatalf("Prune without limits = %d, %v", n, err)
+	}
+
+	s.SetLimits(24*time.Hour, 2)
+	if n, err := s.Prune(); err != nil || n != 2 {
+		t.Fatalf("Prune = %d, %v, want 2 removed", n, err)
+	}
+	records, _ := s.Records("", time.Time{})
+	if len(records) != 2 || records[0].Time.Before(now.Add(-90*time.Minute)) {
+		t.Errorf("records after prune = %+v", records)
+	}
+
+	reopened, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := reopened.Records("", time.Time{}); len(records) != 2 {
+		t.Errorf("file has %d records after prune, want 2", len(records))
+	}
+}
+
+func TestStoreDoesNotShareResults(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []models.DeviceResult{{IP: "1.1.1.1", LatencyMs: 20}, {IP: "1.0.0.1", LatencyMs: 10}}
+	if err := s.Append(Record{Time: time.Now(), Version: "v4", Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	// 调用方在 Append 之后修改自己的切片
+	results[0], results[1] = results[1], results[0]
+
+	latest, err := s.Latest("v4")
+	if err != nil || latest == nil {
+		t.Fatalf("Latest(v4) = %+v, %v", latest, err)
+	}
+	if latest.Results[0].IP != "1.1.1.1" {
+		t.Fatalf("stored results changed with caller slice: %+v", latest.Results)
+	}
+	// 修改返回的记录
+	latest.Results[0].IP = "9.9.9.9"
+	latest.Results = append(latest.Results[:0], latest.Results[1])
+
+	records, _ := s.Records("v4", time.Time{})
+	if len(records) != 1 || len(records[0].Results) != 2 || records[0].Results[0].IP != "1.1.1.1" {
+		t.Errorf("store changed through returned record: %+v", records)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	path := filepath.Join(dir, "history.jsonl")
+	if _, err := OpenReadOnly(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("OpenReadOnly on a missing file: err = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("OpenReadOnly created %s", dir)
+	}
+
+	s, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(Record{Time: time.Now(), Version: "v4", Results: []models.DeviceResult{{IP: "1.1.1.1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	ro, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := ro.Latest("v4"); err != nil || rec == nil || rec.Results[0].IP != "1.1.1.1" {
+		t.Errorf("Latest = %+v, %v", rec, err)
+	}
+	if err := ro.Append(Record{Version: "v4"}); err == nil {
+		t.Error("Append on a read-only store succeeded")
+	}
+	if _, err := ro.Prune(); err == nil {
+		t.Error("Prune on a read-only store succeeded")
+	}
+}
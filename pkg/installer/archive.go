@@ -0,0 +1,195 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// archiveSuffixes 是支持的压缩包格式
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".zip"}
+
+// archAliases 返回资源名称中可能使用的架构标识，如 arm 对应 armv7/armv6/armv5
+func archAliases(goarch string) []string {
+	switch goarch {
+	case "arm":
+		return []string{"armv7", "armv6", "armv5", "arm"}
+	case "386":
+		return []string{"386", "i386"}
+	}
+	return []string{goarch}
+}
+
+// findPlatformAsset 查找当前平台的压缩包，如 cfst_linux_amd64.tar.gz 或 cfst_windows_amd64.zip
+func findPlatformAsset(assets []ReleaseAsset, goos, goarch string) *ReleaseAsset {
+	for _, arch := range archAliases(goarch) {
+		id := goos + "_" + arch
+		for idx := range assets {
+			name := strings.ToLower(assets[idx].Name)
+			if !strings.Contains(name, id) {
+				continue
+			}
+			// 避免 arm 匹配到 arm64
+			if rest := name[strings.Index(name, id)+len(id):]; rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+				continue
+			}
+			for _, suffix := range archiveSuffixes {
+				if strings.HasSuffix(name, suffix) {
+					return &assets[idx]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// executableNames 返回压缩包中可执行程序可能的文件名，旧版本使用 CloudflareST
+func executableNames(goos string) []string {
+	if goos == "windows" {
+		return []string{"cfst.exe", "CloudflareST.exe"}
+	}
+	return []string{"cfst", "CloudflareST"}
+}
+
+// unpack 根据扩展名解压压缩包，可执行程序解压到 binPath 同目录下的临时文件，
+// IP 列表只读入内存。返回临时文件路径和 IP 列表，由调用方在冒烟测试和替换 binPath 成功后
+// 再通过 commitIPLists 写入配置目录。
+func (i *Installer) unpack(archive string) (newBin string, ipLists map[string][]byte, err error) {
+	if err := os.MkdirAll(filepath.Dir(i.binPath), 0755); err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(i.configDir, 0755); err != nil {
+		return "", nil, err
+	}
+
+	x := &extractor{installer: i, exeNames: executableNames(runtime.GOOS), ipLists: map[string][]byte{}}
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		err = x.zip(archive)
+	} else {
+		err = x.tarGz(archive)
+	}
+	if err != nil {
+		return x.newBin, nil, err
+	}
+	if x.newBin == "" {
+		return "", nil, fmt.Errorf("executable %s not found in archive", strings.Join(x.exeNames, " or "))
+	}
+	return x.newBin, x.ipLists, nil
+}
+
+// extractor 保存一次解压的状态，压缩包中的目录层级会被忽略，只按文件名匹配
+type extractor struct {
+	installer *Installer
+	exeNames  []string
+	exeRank   int // 已解压的可执行程序在 exeNames 中的位置，用于优先选择新名称
+	newBin    string
+	ipLists   map[string][]byte // 文件名 -> 内容，暂存到新程序安装成功后再写入
+}
+
+func (x *extractor) tarGz(archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := x.entry(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extractor) zip(archive string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = x.entry(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry 处理压缩包中的一个文件
+func (x *extractor) entry(name string, r io.Reader) error {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	i := x.installer
+
+	switch base {
+	case "ip.txt", "ipv6.txt":
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		// [修改] 先暂存，新程序通过冒烟测试并替换成功后才按 update.ip_list 策略写入
+		x.ipLists[base] = data
+		return nil
+	}
+
+	// [核心修正] 只接受指定名称的文件作为可执行程序，忽略同名的脚本等其他文件
+	rank := -1
+	for idx, n := range x.exeNames {
+		if base == n {
+			rank = idx
+		}
+	}
+	if rank < 0 || (x.newBin != "" && rank >= x.exeRank) {
+		return nil
+	}
+
+	// 先解压到同目录下的临时文件，确保之后的重命名是原子的
+	out, err := os.CreateTemp(filepath.Dir(i.binPath), "."+filepath.Base(i.binPath)+".*.new")
+	if err != nil {
+		return err
+	}
+	if x.newBin != "" {
+		_ = os.Remove(x.newBin)
+	}
+	x.newBin, x.exeRank = out.Name(), rank
+	log.Printf("Extracting executable '%s' to '%s'", name, x.newBin)
+	_, err = io.Copy(out, r)
+	out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Chmod(x.newBin, 0755)
+}
@@ -0,0 +1,202 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+// archiveFile 是测试压缩包中的一个文件
+type archiveFile struct {
+	name, data string
+}
+
+func makeTarGz(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "cfst/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(f.data))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.data))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeZip(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("cfst/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFindPlatformAsset(t *testing.T) {
+	assets := []ReleaseAsset{
+		{Name: "cfst_linux_arm64.tar.gz"},
+		{Name: "cfst_linux_armv7.tar.gz"},
+		{Name: "cfst_linux_armv5.tar.gz"},
+		{Name: "cfst_linux_amd64.tar.gz"},
+		{Name: "cfst_linux_amd64.tar.gz.sha256"},
+		{Name: "cfst_linux_386.tar.gz"},
+		{Name: "cfst_windows_amd64.zip"},
+		{Name: "cfst_darwin_arm64.zip"},
+		{Name: "CloudflareST_freebsd_i386.tgz"},
+	}
+	tests := []struct {
+		goos, goarch string
+		want         string
+	}{
+		{"linux", "amd64", "cfst_linux_amd64.tar.gz"},
+		{"linux", "arm64", "cfst_linux_arm64.tar.gz"},
+		{"linux", "arm", "cfst_linux_armv7.tar.gz"},
+		{"linux", "386", "cfst_linux_386.tar.gz"},
+		{"windows", "amd64", "cfst_windows_amd64.zip"},
+		{"darwin", "arm64", "cfst_darwin_arm64.zip"},
+		{"freebsd", "386", "CloudflareST_freebsd_i386.tgz"},
+		{"darwin", "amd64", ""},
+	}
+	for _, tt := range tests {
+		got := findPlatformAsset(assets, tt.goos, tt.goarch)
+		name := ""
+		if got != nil {
+			name = got.Name
+		}
+		if name != tt.want {
+			t.Errorf("findPlatformAsset(%s, %s) = %q, want %q", tt.goos, tt.goarch, name, tt.want)
+		}
+	}
+
+	// 只有 arm64 资源时 arm 不能误匹配
+	if got := findPlatformAsset(assets[:1], "linux", "arm"); got != nil {
+		t.Errorf("arm matched %s", got.Name)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	exe := executableNames(runtime.GOOS)
+	tests := []struct {
+		name    string
+		archive string
+		build   func(*testing.T, []archiveFile) []byte
+		files   []archiveFile
+		wantBin string
+		wantIP  string
+		wantErr bool
+	}{
+		{
+			name:    "tar.gz",
+			archive: "cfst_linux_amd64.tar.gz",
+			build:   makeTarGz,
+			files:   []archiveFile{{"cfst/" + exe[0], "new binary"}, {"cfst/ip.txt", "1.0.0.0/24\n"}, {"cfst/使用说明.txt", "readme"}},
+			wantBin: "new binary",
+			wantIP:  "1.0.0.0/24\n",
+		},
+		{
+			name:    "zip with windows separators",
+			archive: "cfst_windows_amd64.zip",
+			build:   makeZip,
+			files:   []archiveFile{{`cfst\` + exe[0], "new binary"}, {`cfst\ip.txt`, "1.0.0.0/24\n"}},
+			wantBin: "new binary",
+			wantIP:  "1.0.0.0/24\n",
+		},
+		{
+			name:    "new name wins when listed second",
+			archive: "cfst.tar.gz",
+			build:   makeTarGz,
+			files:   []archiveFile{{exe[1], "old name"}, {exe[0], "new name"}},
+			wantBin: "new name",
+		},
+		{
+			name:    "new name wins when listed first",
+			archive: "cfst.zip",
+			build:   makeZip,
+			files:   []archiveFile{{exe[0], "new name"}, {exe[1], "old name"}},
+			wantBin: "new name",
+		},
+		{
+			name:    "old name only",
+			archive: "cfst.zip",
+			build:   makeZip,
+			files:   []archiveFile{{exe[1], "old name"}},
+			wantBin: "old name",
+		},
+		{
+			name:    "similar names are not executables",
+			archive: "cfst.tar.gz",
+			build:   makeTarGz,
+			files:   []archiveFile{{exe[0] + ".sh", "script"}, {"ip.txt", "1.0.0.0/24\n"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, tt.archive)
+			if err := os.WriteFile(archive, tt.build(t, tt.files), 0644); err != nil {
+				t.Fatal(err)
+			}
+			i := NewInstaller("", config.UpdateConfig{}, filepath.Join(dir, "bin", "cfst"), filepath.Join(dir, "config"))
+			newBin, ipLists, err := i.unpack(archive)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unpack succeeded with %s, want error", newBin)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unpack: %v", err)
+			}
+			if got := mustRead(t, newBin); got != tt.wantBin {
+				t.Errorf("extracted binary = %q, want %q", got, tt.wantBin)
+			}
+			if filepath.Dir(newBin) != filepath.Join(dir, "bin") {
+				t.Errorf("binary extracted to %s, want next to binPath", newBin)
+			}
+			if fi, err := os.Stat(newBin); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm()&0100 == 0 {
+				t.Errorf("extracted binary mode = %v, want executable", fi.Mode())
+			}
+			entries, _ := os.ReadDir(filepath.Join(dir, "bin"))
+			if len(entries) != 1 {
+				t.Errorf("bin dir has %d files, want only the new binary", len(entries))
+			}
+			if got := string(ipLists["ip.txt"]); got != tt.wantIP {
+				t.Errorf("ip.txt = %q, want %q", got, tt.wantIP)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "config", "ip.txt")); !os.IsNotExist(err) {
+				t.Errorf("ip.txt written to config dir before the binary was installed")
+			}
+		})
+	}
+}
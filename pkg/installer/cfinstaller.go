@@ -1,17 +1,21 @@
 package installer
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings" // [FIX] Import the strings package
+	"time"
+
+	"cfst-client/pkg/config"
 )
 
 // ... (ReleaseAsset and ReleaseInfo structs remain the same) ...
@@ -20,8 +24,17 @@ type ReleaseAsset struct {
 	BrowserDownloadURL string `json:"browser_download_url"`
 }
 type ReleaseInfo struct {
-	TagName string         `json:"tag_name"`
-	Assets  []ReleaseAsset `json:"assets"`
+	TagName    string         `json:"tag_name"`
+	Draft      bool           `json:"draft"`
+	Prerelease bool           `json:"prerelease"`
+	Assets     []ReleaseAsset `json:"assets"`
+}
+
+// Result 描述一次更新检查的结果
+type Result struct {
+	Version string // 当前安装的版本标签
+	Updated bool   // 本次是否安装了新版本
+	SHA256  string // 本次下载的压缩包的 SHA-256
 }
 
 type Installer struct {
@@ -30,58 +43,128 @@ type Installer struct {
 	binPath   string
 	configDir string
 	cacheFile string
+	policy    config.UpdateConfig
+	verify    config.UpdateVerifyConfig
 }
 
-func NewInstaller(proxy, apiURL, binPath, configDir string) *Installer {
+func NewInstaller(proxy string, cfg config.UpdateConfig, binPath, configDir string) *Installer {
 	return &Installer{
 		proxy:     proxy,
-		apiURL:    apiURL,
+		apiURL:    cfg.ApiURL,
 		binPath:   binPath,
 		configDir: configDir,
 		cacheFile: binPath + ".version",
+		policy:    cfg,
+		verify:    cfg.Verify,
 	}
 }
 
-func (i *Installer) InstallOrUpdate() error {
-	api := i.apiURL
-	if i.proxy != "" {
-		api = i.proxy + api
+// fetch 下载一个较小的文件（如 Release 信息、校验文件和签名）。优先直连 GitHub，
+// 直连失败且配置了 proxy_prefix 时改经代理下载，此时 proxied 为 true，内容来自不可信的镜像。
+func (i *Installer) fetch(rawURL string) (data []byte, proxied bool, err error) {
+	data, err = get(rawURL)
+	if err == nil || i.proxy == "" {
+		return data, false, err
 	}
-	resp, err := http.Get(api)
+	log.Printf("WARN: Failed to fetch %s directly (%v); retrying through proxy_prefix.", rawURL, err)
+	data, perr := get(i.proxy + rawURL)
+	if perr != nil {
+		return nil, true, fmt.Errorf("%v; via proxy: %w", err, perr)
+	}
+	return data, true, nil
+}
+
+// get 下载 rawURL 的内容，非 200 状态视为失败
+func get(rawURL string) ([]byte, error) {
+	resp, err := http.Get(rawURL)
 	if err != nil {
-		return fmt.Errorf("fetch release info: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+}
+
+// findAsset 按名称查找 Release 资源
+func findAsset(assets []ReleaseAsset, name string) *ReleaseAsset {
+	for idx := range assets {
+		if assets[idx].Name == name {
+			return &assets[idx]
+		}
+	}
+	return nil
+}
+
+// InstallOrUpdate 检查最新版本并在需要时下载安装
+func (i *Installer) InstallOrUpdate() (*Result, error) {
+	current := i.installedVersion()
+	if _, err := os.Stat(i.binPath); err != nil {
+		current = "" // 程序不存在时无论缓存如何都需要安装
+	}
 
+	// [新增] 未到最小检查间隔时跳过
+	if interval := time.Duration(i.policy.MinIntervalHours) * time.Hour; interval > 0 && current != "" && i.policy.Pin == "" {
+		if fi, err := os.Stat(i.binPath + checkedSuffix); err == nil && time.Since(fi.ModTime()) < interval {
+			log.Printf("CloudflareSpeedTest update was checked at %s; next check after %s.",
+				fi.ModTime().Format("2006-01-02 15:04:05"), fi.ModTime().Add(interval).Format("2006-01-02 15:04:05"))
+			return &Result{Version: current}, nil
+		}
+	}
+
+	// [修改] Release 信息优先直接从 GitHub API 获取，失败时才经过代理镜像
+	api, list := i.releaseURL()
+	data, proxied, err := i.fetch(api)
+	if err != nil {
+		return nil, fmt.Errorf("fetch release info: %w", err)
+	}
 	var info ReleaseInfo
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return fmt.Errorf("decode release: %w", err)
+	if list {
+		var releases []ReleaseInfo
+		if err := json.Unmarshal(data, &releases); err != nil {
+			return nil, fmt.Errorf("decode releases: %w", err)
+		}
+		r := i.pickRelease(current, releases)
+		if r == nil {
+			return nil, fmt.Errorf("no published release found at %s", api)
+		}
+		info = *r
+	} else if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("decode release: %w", err)
+	}
+	if err := writeFileAtomic(i.binPath+checkedSuffix, []byte(time.Now().Format(time.RFC3339)), 0644); err != nil {
+		log.Printf("WARN: Failed to record update check time: %v", err)
 	}
 
 	// [FIX] Trim whitespace from the cached version string before comparing
-	if data, err := os.ReadFile(i.cacheFile); err == nil && strings.TrimSpace(string(data)) == info.TagName {
+	if current == info.TagName {
 		log.Println("CloudflareSpeedTest is already the latest version:", info.TagName)
-		return nil
+		return &Result{Version: info.TagName}, nil
 	}
 
-	log.Println("New CloudflareSpeedTest version found:", info.TagName)
-
-	targetFilename := fmt.Sprintf("cfst_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
-	var assetURL string
-	archIdentifier := fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH)
+	// [新增] 按更新策略决定是否安装
+	if reason := i.rejectByPolicy(current, &info); reason != "" {
+		log.Printf("Skipping CloudflareSpeedTest %s: %s.", info.TagName, reason)
+		return &Result{Version: current}, nil
+	}
 
-	for _, a := range info.Assets {
-		if strings.Contains(a.Name, archIdentifier) && strings.HasSuffix(a.Name, ".tar.gz") {
-			assetURL = a.BrowserDownloadURL
-			targetFilename = a.Name
-			log.Println("Found matching asset:", targetFilename)
-			break
-		}
+	// [新增] 曾因无法执行而回滚的版本不再自动安装
+	if data, err := os.ReadFile(i.binPath + rejectedSuffix); err == nil && strings.TrimSpace(string(data)) == info.TagName {
+		log.Printf("CloudflareSpeedTest %s was rolled back after failing to run; skipping it.", info.TagName)
+		return &Result{Version: current}, nil
 	}
 
-	if assetURL == "" {
-		return fmt.Errorf("asset for %s not found in release assets", archIdentifier)
+	log.Println("New CloudflareSpeedTest version found:", info.TagName)
+
+	// [修改] 按平台匹配资源，支持 .tar.gz 和 .zip 压缩包
+	asset := findPlatformAsset(info.Assets, runtime.GOOS, runtime.GOARCH)
+	if asset == nil {
+		return nil, fmt.Errorf("asset for %s_%s not found in release assets", runtime.GOOS, runtime.GOARCH)
 	}
+	assetURL := asset.BrowserDownloadURL
+	targetFilename := asset.Name
+	log.Println("Found matching asset:", targetFilename)
 
 	dlURL := assetURL
 	if i.proxy != "" {
@@ -93,103 +176,147 @@ func (i *Installer) InstallOrUpdate() error {
 	tmp := filepath.Join(os.TempDir(), targetFilename)
 	out, err := os.Create(tmp)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	defer os.Remove(tmp)
+
 	resp2, err := http.Get(dlURL)
 	if err != nil {
 		out.Close()
-		return fmt.Errorf("download asset: %w", err)
+		return nil, fmt.Errorf("download asset: %w", err)
 	}
 	defer resp2.Body.Close()
+	if resp2.StatusCode != http.StatusOK {
+		out.Close()
+		return nil, fmt.Errorf("download asset: unexpected status: %s", resp2.Status)
+	}
 
-	_, err = io.Copy(out, resp2.Body)
+	// 边下载边计算哈希
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, hasher), resp2.Body)
 	out.Close()
 	if err != nil {
-		return fmt.Errorf("save asset: %w", err)
+		return nil, fmt.Errorf("save asset: %w", err)
 	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	log.Printf("Downloaded %s (sha256 %s).", targetFilename, sum)
 
-	log.Println("Unpacking archive to specified directories...")
-	if err := i.unpackTarGz(tmp); err != nil {
-		return fmt.Errorf("unpack: %w", err)
+	// [新增] 代理镜像不可信，解压前校验压缩包
+	if err := i.verifyArchive(&info, proxied, targetFilename, sum); err != nil {
+		return nil, fmt.Errorf("verify %s: %w", targetFilename, err)
 	}
-	log.Println("Unpack successful.")
 
-	if err := os.WriteFile(i.cacheFile, []byte(info.TagName), 0644); err != nil {
-		return err
+	log.Println("Unpacking archive to specified directories...")
+	newBin, ipLists, err := i.unpack(tmp)
+	if newBin != "" {
+		// 替换成功后临时文件已不存在，删除只在失败时生效
+		defer os.Remove(newBin)
 	}
-	log.Println("Version cache updated.")
-	return nil
-}
-
-// ... (unpackTarGz function remains the same) ...
-func (i *Installer) unpackTarGz(archive string) error {
-	f, err := os.Open(archive)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("unpack: %w", err)
 	}
-	defer f.Close()
+	log.Println("Unpack successful.")
 
-	gr, err := gzip.NewReader(f)
-	if err != nil {
-		return err
+	// [新增] 确认新程序可以运行后再替换，失败时保留当前版本
+	if err := smokeTest(newBin); err != nil {
+		return nil, fmt.Errorf("smoke test of %s failed: %w", info.TagName, err)
 	}
-	defer gr.Close()
-
-	tr := tar.NewReader(gr)
-	if err := os.MkdirAll(filepath.Dir(i.binPath), 0755); err != nil {
-		return err
+	if err := i.replaceBinary(newBin, info.TagName); err != nil {
+		return nil, err
 	}
-	if err := os.MkdirAll(i.configDir, 0755); err != nil {
-		return err
+	log.Println("Binary replaced and version cache updated.")
+	// [修改] IP 列表在新程序安装成功后才写入，备份记录在待确认标记中，回滚时一并恢复
+	i.recordPendingBackups(i.commitIPLists(ipLists))
+	return &Result{Version: info.TagName, Updated: true, SHA256: sum}, nil
+}
+
+// releaseURL 返回查询 Release 的地址，list 表示返回的是 Release 列表。
+// 固定版本时查询 .../releases/tags/<pin>；允许预发布版本时查询 .../releases，
+// 因为 /releases/latest 只返回最新的正式版；其余情况直接使用 api_url。
+func (i *Installer) releaseURL() (api string, list bool) {
+	base, ok := strings.CutSuffix(strings.TrimRight(i.apiURL, "/"), "/latest")
+	switch {
+	case i.policy.Pin != "":
+		return base + "/tags/" + url.PathEscape(i.policy.Pin), false
+	case i.policy.Prerelease && ok:
+		return base + "?per_page=30", true
 	}
+	return i.apiURL, false
+}
 
-	executableFound := false
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
+// pickRelease 从 Release 列表中选出符合更新策略的最高版本，忽略草稿。
+// 没有符合策略的版本时返回最高版本，由调用方记录跳过的原因；版本号都无法解析时返回最新发布的一个。
+func (i *Installer) pickRelease(current string, releases []ReleaseInfo) *ReleaseInfo {
+	var best, bestAllowed, first *ReleaseInfo
+	var bestVer, bestAllowedVer semver
+	for idx := range releases {
+		r := &releases[idx]
+		if r.Draft {
+			continue
 		}
-		if err != nil {
-			return err
+		if first == nil {
+			first = r
 		}
-		if hdr.Typeflag != tar.TypeReg {
+		v, ok := parseSemver(r.TagName)
+		if !ok {
 			continue
 		}
-
-		var destPath string
-		var filePerm os.FileMode
-
-		switch hdr.Name {
-		case "ip.txt", "ipv6.txt":
-			destPath = filepath.Join(i.configDir, hdr.Name)
-			filePerm = 0644
-			log.Printf("Extracting '%s' to '%s'", hdr.Name, destPath)
-		// [核心修正] 我们只接受名为 "cfst" 的文件作为可执行程序
-		case "cfst":
-			destPath = i.binPath
-			filePerm = 0755
-			log.Printf("Extracting executable '%s' to '%s'", hdr.Name, destPath)
-			executableFound = true
-		default:
-			// 忽略所有其他文件，特别是那个同名的脚本
-			continue
+		if best == nil || v.compare(bestVer) > 0 {
+			best, bestVer = r, v
 		}
+		if i.rejectByPolicy(current, r) == "" && (bestAllowed == nil || v.compare(bestAllowedVer) > 0) {
+			bestAllowed, bestAllowedVer = r, v
+		}
+	}
+	switch {
+	case bestAllowed != nil:
+		return bestAllowed
+	case best != nil:
+		return best
+	}
+	return first
+}
 
-		out, err := os.OpenFile(destPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, filePerm)
-		if err != nil {
-			return err
+// rejectByPolicy 判断新版本是否违反更新策略，返回跳过的原因，允许安装时返回空字符串。
+// 固定版本时总是安装指定的标签（包括降级）；当前未安装时只检查预发布限制。
+func (i *Installer) rejectByPolicy(current string, info *ReleaseInfo) string {
+	if i.policy.Pin != "" {
+		return ""
+	}
+	next, ok := parseSemver(info.TagName)
+	if (info.Prerelease || (ok && next.pre != "")) && !i.policy.Prerelease {
+		return "pre-releases are disabled"
+	}
+	if current == "" {
+		return ""
+	}
+	cur, curOK := parseSemver(current)
+	if !ok || !curOK {
+		log.Printf("WARN: Cannot compare versions %q and %q as semver; treating the new tag as an upgrade.", current, info.TagName)
+		return ""
+	}
+	if next.compare(cur) <= 0 {
+		return fmt.Sprintf("not newer than the installed %s", current)
+	}
+	switch i.policy.Policy {
+	case "minor":
+		if next.major != cur.major {
+			return fmt.Sprintf("policy 'minor' does not allow upgrading from %s across major versions", current)
 		}
-		if _, err := io.Copy(out, tr); err != nil {
-			out.Close()
-			return err
+	case "patch":
+		if next.major != cur.major || next.minor != cur.minor {
+			return fmt.Sprintf("policy 'patch' does not allow upgrading from %s across minor versions", current)
 		}
-		out.Close()
 	}
+	return ""
+}
 
-	if !executableFound {
-		return fmt.Errorf("executable 'cfst' not found in archive")
+// installedVersion 返回版本缓存中记录的当前版本
+func (i *Installer) installedVersion() string {
+	data, err := os.ReadFile(i.cacheFile)
+	if err != nil {
+		return ""
 	}
-
-	return nil
-}
\ No newline at end of file
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,169 @@
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IP 列表的更新策略
+const (
+	IPListAlways        = "always"          // 总是使用压缩包中的列表覆盖
+	IPListNever         = "never"           // 从不写入
+	IPListOnlyIfMissing = "only-if-missing" // 仅在文件不存在时写入（默认）
+	IPListMerge         = "merge"           // 保留现有内容，追加压缩包中新增的 IP 段
+)
+
+// maxLoggedRanges 是日志中每类变化最多列出的 IP 段数量
+const maxLoggedRanges = 20
+
+// commitIPLists 在新程序安装成功后写入暂存的 IP 列表，返回本次生成了 .bak 备份的文件路径。
+// 写入失败只记录警告，不影响已经完成的程序更新。
+func (i *Installer) commitIPLists(lists map[string][]byte) []string {
+	names := make([]string, 0, len(lists))
+	for name := range lists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var backedUp []string
+	for _, name := range names {
+		backup, err := i.updateIPList(name, lists[name])
+		if err != nil {
+			log.Printf("WARN: Failed to update %s: %v", name, err)
+			continue
+		}
+		if backup {
+			backedUp = append(backedUp, filepath.Join(i.configDir, name))
+		}
+	}
+	return backedUp
+}
+
+// updateIPList 按 update.ip_list 策略把压缩包中的 ip.txt/ipv6.txt 写入配置目录。
+// 内容变化时旧文件保留为 .bak，并记录新增和移除的 IP 段，返回是否生成了备份。
+func (i *Installer) updateIPList(name string, data []byte) (backup bool, err error) {
+	destPath := filepath.Join(i.configDir, name)
+	policy := i.policy.IPList
+	if policy == "" {
+		policy = IPListOnlyIfMissing
+	}
+
+	old, err := os.ReadFile(destPath)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	switch policy {
+	case IPListNever:
+		log.Printf("Skipping %s from the archive (ip_list policy 'never').", name)
+		return false, nil
+	case IPListOnlyIfMissing:
+		if exists {
+			log.Printf("Keeping existing %s (ip_list policy 'only-if-missing').", destPath)
+			return false, nil
+		}
+	case IPListMerge:
+		if exists {
+			data = mergeIPList(old, data)
+		}
+	case IPListAlways:
+	default:
+		return false, fmt.Errorf("unknown ip_list policy %q", policy)
+	}
+
+	if exists && bytes.Equal(old, data) {
+		log.Printf("%s is unchanged.", destPath)
+		return false, nil
+	}
+	if exists {
+		if err := writeFileAtomic(destPath+backupSuffix, old, 0644); err != nil {
+			return false, fmt.Errorf("backup %s: %w", name, err)
+		}
+	}
+	if err := writeFileAtomic(destPath, data, 0644); err != nil {
+		return false, err
+	}
+
+	added, removed := diffIPRanges(ipRanges(old), ipRanges(data))
+	if exists {
+		log.Printf("Updated %s (policy '%s', previous list saved as %s): %d ranges added, %d removed.",
+			destPath, policy, filepath.Base(destPath+backupSuffix), len(added), len(removed))
+	} else {
+		log.Printf("Installed %s with %d ranges.", destPath, len(added))
+		return false, nil
+	}
+	if len(added) > 0 {
+		log.Printf("  Added to %s: %s", name, summarizeRanges(added))
+	}
+	if len(removed) > 0 {
+		log.Printf("  Removed from %s: %s", name, summarizeRanges(removed))
+	}
+	return true, nil
+}
+
+// ipRanges 返回列表中的 IP 段，忽略空行和 # 注释
+func ipRanges(data []byte) []string {
+	var ranges []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ranges = append(ranges, line)
+	}
+	return ranges
+}
+
+// mergeIPList 保留 old 的全部内容（包括注释和顺序），并在末尾追加 update 中新增的 IP 段
+func mergeIPList(old, update []byte) []byte {
+	added, _ := diffIPRanges(ipRanges(old), ipRanges(update))
+	if len(added) == 0 {
+		return old
+	}
+	var buf bytes.Buffer
+	buf.Write(old)
+	if len(old) > 0 && !bytes.HasSuffix(old, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	for _, r := range added {
+		buf.WriteString(r)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// diffIPRanges 返回 next 相对 prev 新增和移除的 IP 段，保持原有顺序
+func diffIPRanges(prev, next []string) (added, removed []string) {
+	inPrev := make(map[string]bool, len(prev))
+	for _, r := range prev {
+		inPrev[r] = true
+	}
+	inNext := make(map[string]bool, len(next))
+	for _, r := range next {
+		if !inPrev[r] && !inNext[r] {
+			added = append(added, r)
+		}
+		inNext[r] = true
+	}
+	for _, r := range prev {
+		if !inNext[r] {
+			removed = append(removed, r)
+			inNext[r] = true // 去重
+		}
+	}
+	return added, removed
+}
+
+// summarizeRanges 把 IP 段拼接成一行，过长时省略剩余部分
+func summarizeRanges(ranges []string) string {
+	if len(ranges) <= maxLoggedRanges {
+		return strings.Join(ranges, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(ranges[:maxLoggedRanges], ", "), len(ranges)-maxLoggedRanges)
+}
@@ -0,0 +1,150 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+func TestUpdateIPList(t *testing.T) {
+	const local = "# my ranges\n1.1.1.0/24\n\n2.2.2.0/24\n"
+	const update = "2.2.2.0/24\n3.3.3.0/24\n3.3.3.0/24\n"
+	tests := []struct {
+		policy     string
+		existing   string // 为空表示文件不存在
+		want       string
+		wantBackup bool
+	}{
+		{IPListAlways, local, update, true},
+		{IPListAlways, "", update, false},
+		{IPListAlways, update, update, false}, // 内容未变化时不备份
+		{IPListNever, local, local, false},
+		{IPListNever, "", "", false},
+		{IPListOnlyIfMissing, local, local, false},
+		{IPListOnlyIfMissing, "", update, false},
+		{"", local, local, false}, // 默认 only-if-missing
+		{IPListMerge, local, local + "3.3.3.0/24\n", true},
+		{IPListMerge, "", update, false},
+		{IPListMerge, "1.1.1.0/24\n2.2.2.0/24\n3.3.3.0/24\n", "1.1.1.0/24\n2.2.2.0/24\n3.3.3.0/24\n", false},
+	}
+	for _, tt := range tests {
+		name := tt.policy
+		if name == "" {
+			name = "default"
+		}
+		if tt.existing == "" {
+			name += "/missing"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "ip.txt")
+			if tt.existing != "" {
+				mustWrite(t, path, tt.existing)
+			}
+			i := NewInstaller("", config.UpdateConfig{IPList: tt.policy}, filepath.Join(dir, "cfst"), dir)
+			backup, err := i.updateIPList("ip.txt", []byte(update))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if backup != tt.wantBackup {
+				t.Errorf("backup = %v, want %v", backup, tt.wantBackup)
+			}
+			got, err := os.ReadFile(path)
+			if tt.want == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("ip.txt was written: %q", got)
+				}
+			} else if string(got) != tt.want {
+				t.Errorf("ip.txt = %q, want %q", got, tt.want)
+			}
+			bak, err := os.ReadFile(path + backupSuffix)
+			if tt.wantBackup && string(bak) != tt.existing {
+				t.Errorf("ip.txt.bak = %q, want previous content %q", bak, tt.existing)
+			}
+			if !tt.wantBackup && err == nil {
+				t.Errorf("unexpected ip.txt.bak: %q", bak)
+			}
+		})
+	}
+
+	i := NewInstaller("", config.UpdateConfig{IPList: "sometimes"}, "cfst", t.TempDir())
+	if _, err := i.updateIPList("ip.txt", []byte(update)); err == nil {
+		t.Errorf("unknown policy accepted")
+	}
+}
+
+func TestMergeIPList(t *testing.T) {
+	tests := []struct {
+		name, old, update, want string
+	}{
+		{"keeps comments and order", "# office\n2.2.2.0/24\n# home\n1.1.1.0/24\n", "1.1.1.0/24\n4.4.4.0/24\n",
+			"# office\n2.2.2.0/24\n# home\n1.1.1.0/24\n4.4.4.0/24\n"},
+		{"de-duplicates new ranges", "1.1.1.0/24\n", "4.4.4.0/24\n 4.4.4.0/24 \n1.1.1.0/24\n",
+			"1.1.1.0/24\n4.4.4.0/24\n"},
+		{"adds missing trailing newline", "1.1.1.0/24", "4.4.4.0/24\n", "1.1.1.0/24\n4.4.4.0/24\n"},
+		{"keeps local ranges removed upstream", "1.1.1.0/24\n9.9.9.0/24\n", "1.1.1.0/24\n", "1.1.1.0/24\n9.9.9.0/24\n"},
+		{"ignores update comments", "1.1.1.0/24\n", "# upstream\n1.1.1.0/24\n", "1.1.1.0/24\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(mergeIPList([]byte(tt.old), []byte(tt.update))); got != tt.want {
+				t.Errorf("mergeIPList = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffIPRanges(t *testing.T) {
+	added, removed := diffIPRanges(
+		[]string{"1.1.1.0/24", "2.2.2.0/24", "2.2.2.0/24", "5.5.5.0/24"},
+		[]string{"3.3.3.0/24", "1.1.1.0/24", "3.3.3.0/24", "4.4.4.0/24"},
+	)
+	if want := []string{"3.3.3.0/24", "4.4.4.0/24"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+	if want := []string{"2.2.2.0/24", "5.5.5.0/24"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+}
+
+func TestRollbackRestoresMergedIPListAndKeepsUntouchedOnes(t *testing.T) {
+	dir := t.TempDir()
+	binPath := filepath.Join(dir, "cfst")
+	mustWrite(t, binPath, "old binary")
+	mustWrite(t, binPath+".version", "v1.0.0")
+	mustWrite(t, filepath.Join(dir, "ip.txt"), "# mine\n1.1.1.0/24\n")
+	newBin := filepath.Join(dir, "cfst.new")
+	mustWrite(t, newBin, "new binary")
+
+	i := NewInstaller("", config.UpdateConfig{IPList: IPListMerge}, binPath, dir)
+	if err := i.replaceBinary(newBin, "v2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	backups := i.commitIPLists(map[string][]byte{
+		"ip.txt":   []byte("2.2.2.0/24\n"),
+		"ipv6.txt": []byte("2606:4700::/32\n"), // 不存在，直接安装，不产生备份
+	})
+	if want := []string{filepath.Join(dir, "ip.txt")}; !reflect.DeepEqual(backups, want) {
+		t.Fatalf("backups = %v, want %v", backups, want)
+	}
+	i.recordPendingBackups(backups)
+	if got := mustRead(t, filepath.Join(dir, "ip.txt.bak")); got != "# mine\n1.1.1.0/24\n" {
+		t.Errorf("ip.txt.bak = %q", got)
+	}
+
+	if rolledBack, err := RollbackPending(binPath); err != nil || !rolledBack {
+		t.Fatalf("RollbackPending = %v, %v", rolledBack, err)
+	}
+	if got := mustRead(t, filepath.Join(dir, "ip.txt")); got != "# mine\n1.1.1.0/24\n" {
+		t.Errorf("ip.txt after rollback = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ip.txt.bak")); !os.IsNotExist(err) {
+		t.Errorf("ip.txt.bak still exists after rollback")
+	}
+	if got := mustRead(t, filepath.Join(dir, "ipv6.txt")); got != "2606:4700::/32\n" {
+		t.Errorf("ipv6.txt after rollback = %q", got)
+	}
+}
@@ -0,0 +1,173 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// smokeTestTimeout 是新程序执行 -h 的最长等待时间
+const smokeTestTimeout = 10 * time.Second
+
+// 备份和待确认标记的文件后缀
+const (
+	backupSuffix   = ".bak"
+	pendingSuffix  = ".pending"
+	rejectedSuffix = ".rejected" // 记录回滚过的版本，之后不再自动安装
+	checkedSuffix  = ".checked"  // 修改时间为最近一次检查更新的时间
+)
+
+// smokeTest 以 -h 参数运行新程序，确认它能在当前平台上正常启动。
+// 退出码不作要求，但程序必须能被执行、在超时前退出且不是被信号终止。
+func smokeTest(bin string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), smokeTestTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, bin, "-h")
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s -h did not exit within %v", filepath.Base(bin), smokeTestTimeout)
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("cannot execute %s: %w", filepath.Base(bin), err)
+	}
+	if cmd.ProcessState != nil && !cmd.ProcessState.Exited() {
+		return fmt.Errorf("%s -h terminated abnormally: %s", filepath.Base(bin), cmd.ProcessState)
+	}
+	return nil
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名，避免中断时留下不完整的文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// replaceBinary 用已通过冒烟测试的 newBin 原子地替换当前程序并更新版本缓存。
+// 旧程序及其版本缓存会保留为 .bak，并写入待确认标记，
+// 若下一次测速无法执行新程序，可通过 RollbackPending 恢复。
+func (i *Installer) replaceBinary(newBin, version string) error {
+	hasPrevious := false
+	if _, err := os.Stat(i.binPath); err == nil {
+		if err := os.Rename(i.binPath, i.binPath+backupSuffix); err != nil {
+			return fmt.Errorf("backup current binary: %w", err)
+		}
+		hasPrevious = true
+		_ = os.Remove(i.cacheFile + backupSuffix)
+		if err := os.Rename(i.cacheFile, i.cacheFile+backupSuffix); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARN: Failed to back up version cache: %v", err)
+		}
+	}
+
+	if err := os.Rename(newBin, i.binPath); err != nil {
+		if hasPrevious {
+			_ = os.Rename(i.binPath+backupSuffix, i.binPath)
+			_ = os.Rename(i.cacheFile+backupSuffix, i.cacheFile)
+		}
+		return fmt.Errorf("install new binary: %w", err)
+	}
+	if err := writeFileAtomic(i.cacheFile, []byte(version), 0644); err != nil {
+		return fmt.Errorf("update version cache: %w", err)
+	}
+	if hasPrevious {
+		if err := writeFileAtomic(i.binPath+pendingSuffix, []byte(version), 0644); err != nil {
+			log.Printf("WARN: Failed to write pending marker, automatic rollback is unavailable: %v", err)
+		}
+	}
+	return nil
+}
+
+// recordPendingBackups 把随本次更新一起备份的文件追加到待确认标记中，
+// 标记第一行为版本号，之后每行一个文件路径。没有待确认标记（首次安装）时不做记录。
+func (i *Installer) recordPendingBackups(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	marker := i.binPath + pendingSuffix
+	data, err := os.ReadFile(marker)
+	if err != nil {
+		return
+	}
+	lines := append(strings.Split(strings.TrimSpace(string(data)), "\n"), paths...)
+	if err := writeFileAtomic(marker, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		log.Printf("WARN: Failed to record backups of %s, they will not be rolled back: %v", strings.Join(paths, ", "), err)
+	}
+}
+
+// readPending 读取待确认标记，返回版本号和随更新一起备份的文件
+func readPending(binPath string) (version string, backups []string, err error) {
+	data, err := os.ReadFile(binPath + pendingSuffix)
+	if err != nil {
+		return "", nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			backups = append(backups, line)
+		}
+	}
+	return strings.TrimSpace(lines[0]), backups, nil
+}
+
+// ConfirmPending 在新程序成功执行后调用，移除待确认标记，备份会保留到下一次更新
+func ConfirmPending(binPath string) {
+	version, _, err := readPending(binPath)
+	if err != nil {
+		return
+	}
+	if err := os.Remove(binPath + pendingSuffix); err == nil {
+		log.Printf("CloudflareSpeedTest %s ran successfully; update confirmed.", version)
+	}
+}
+
+// RollbackPending 在新程序无法执行时恢复备份的旧程序、版本缓存以及随更新写入的 IP 列表。
+// 只有存在待确认标记（即刚更新过）时才会回滚，返回是否执行了回滚。
+func RollbackPending(binPath string) (bool, error) {
+	failed, backups, err := readPending(binPath)
+	if err != nil {
+		return false, nil
+	}
+	cacheFile := binPath + ".version"
+
+	if err := os.Rename(binPath+backupSuffix, binPath); err != nil {
+		return false, fmt.Errorf("restore backup of CloudflareSpeedTest: %w", err)
+	}
+	if err := os.Rename(cacheFile+backupSuffix, cacheFile); err != nil {
+		// 没有旧的版本缓存时删除新版本的缓存，避免缓存与实际程序不一致
+		_ = os.Remove(cacheFile)
+	}
+	for _, p := range backups {
+		if err := os.Rename(p+backupSuffix, p); err != nil {
+			log.Printf("WARN: Failed to restore %s: %v", p, err)
+		} else {
+			log.Printf("Restored %s from %s.", p, filepath.Base(p+backupSuffix))
+		}
+	}
+	_ = os.Remove(binPath + pendingSuffix)
+	if err := writeFileAtomic(binPath+rejectedSuffix, []byte(failed), 0644); err != nil {
+		log.Printf("WARN: Failed to record rejected version %s: %v", failed, err)
+	}
+	log.Printf("Rolled back CloudflareSpeedTest %s to the previous version; it will not be installed again automatically.", failed)
+	return true, nil
+}
@@ -0,0 +1,80 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+func TestRollbackRestoresIPLists(t *testing.T) {
+	dir := t.TempDir()
+	binPath := filepath.Join(dir, "cfst")
+	ipPath := filepath.Join(dir, "ip.txt")
+	mustWrite(t, binPath, "old binary")
+	mustWrite(t, binPath+".version", "v1.0.0")
+	mustWrite(t, ipPath, "1.1.1.0/24\n")
+	newBin := filepath.Join(dir, "cfst.new")
+	mustWrite(t, newBin, "new binary")
+
+	i := NewInstaller("", config.UpdateConfig{IPList: IPListAlways}, binPath, dir)
+	if err := i.replaceBinary(newBin, "v2.0.0"); err != nil {
+		t.Fatalf("replaceBinary: %v", err)
+	}
+	i.recordPendingBackups(i.commitIPLists(map[string][]byte{"ip.txt": []byte("2.2.2.0/24\n")}))
+	if got := mustRead(t, ipPath); got != "2.2.2.0/24\n" {
+		t.Fatalf("ip.txt after update = %q", got)
+	}
+
+	rolledBack, err := RollbackPending(binPath)
+	if err != nil || !rolledBack {
+		t.Fatalf("RollbackPending = %v, %v", rolledBack, err)
+	}
+	if got := mustRead(t, binPath); got != "old binary" {
+		t.Errorf("binary after rollback = %q", got)
+	}
+	if got := mustRead(t, ipPath); got != "1.1.1.0/24\n" {
+		t.Errorf("ip.txt after rollback = %q", got)
+	}
+}
+
+func TestConfirmPendingIgnoresBackupList(t *testing.T) {
+	dir := t.TempDir()
+	binPath := filepath.Join(dir, "cfst")
+	mustWrite(t, binPath, "old binary")
+	mustWrite(t, filepath.Join(dir, "ip.txt"), "1.1.1.0/24\n")
+	newBin := filepath.Join(dir, "cfst.new")
+	mustWrite(t, newBin, "new binary")
+
+	i := NewInstaller("", config.UpdateConfig{IPList: IPListAlways}, binPath, dir)
+	if err := i.replaceBinary(newBin, "v2.0.0"); err != nil {
+		t.Fatalf("replaceBinary: %v", err)
+	}
+	i.recordPendingBackups(i.commitIPLists(map[string][]byte{"ip.txt": []byte("2.2.2.0/24\n")}))
+
+	version, backups, err := readPending(binPath)
+	if err != nil || version != "v2.0.0" || len(backups) != 1 {
+		t.Fatalf("readPending = %q, %v, %v", version, backups, err)
+	}
+	ConfirmPending(binPath)
+	if _, err := os.Stat(binPath + pendingSuffix); !os.IsNotExist(err) {
+		t.Errorf("pending marker still present: %v", err)
+	}
+}
+
+func mustWrite(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
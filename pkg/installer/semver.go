@@ -0,0 +1,93 @@
+package installer
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semver 是解析后的版本标签，如 v2.2.5 或 v2.3.0-beta.1
+type semver struct {
+	major, minor, patch int
+	pre                 string // 预发布标识，正式版为空
+}
+
+// parseSemver 解析版本标签，允许 v 前缀和缺省的 minor/patch（如 v2.3）
+func parseSemver(tag string) (semver, bool) {
+	s := strings.TrimPrefix(strings.TrimSpace(tag), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i] // 忽略构建元数据
+	}
+	var v semver
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, v.pre = s[:i], s[i+1:]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return semver{}, false
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	return v, true
+}
+
+// compare 按语义化版本规则比较，返回 -1、0 或 1
+func (v semver) compare(o semver) int {
+	for _, d := range [][2]int{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		return 1 // 正式版高于同号的预发布版本
+	case o.pre == "":
+		return -1
+	}
+	return comparePrerelease(v.pre, o.pre)
+}
+
+// comparePrerelease 逐段比较预发布标识，数字段按数值比较且低于字母段
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package installer
+
+import (
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want semver
+		ok   bool
+	}{
+		{"v2.2.5", semver{2, 2, 5, ""}, true},
+		{"2.3", semver{2, 3, 0, ""}, true},
+		{" v3 ", semver{3, 0, 0, ""}, true},
+		{"v2.3.0-beta.1", semver{2, 3, 0, "beta.1"}, true},
+		{"v2.3.0+build.7", semver{2, 3, 0, ""}, true},
+		{"v2.3.0-rc.1+build.7", semver{2, 3, 0, "rc.1"}, true},
+		{"latest", semver{}, false},
+		{"v1.2.3.4", semver{}, false},
+		{"v1.-2.3", semver{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSemver(tt.tag)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSemver(%q) = %+v, %v, want %+v, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	// 按语义化版本规范从低到高排列
+	ordered := []string{
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.0.1",
+		"v1.2.0",
+		"v1.10.0",
+		"v2.0.0-beta",
+		"v2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := parseSemver(ordered[i])
+			b, _ := parseSemver(ordered[j])
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := a.compare(b); got != want {
+				t.Errorf("compare(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestRejectByPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  config.UpdateConfig
+		current string
+		next    ReleaseInfo
+		reject  bool
+	}{
+		{"any allows major", config.UpdateConfig{}, "v2.2.5", ReleaseInfo{TagName: "v3.0.0"}, false},
+		{"not newer", config.UpdateConfig{}, "v2.2.5", ReleaseInfo{TagName: "v2.2.4"}, true},
+		{"same version", config.UpdateConfig{}, "v2.2.5", ReleaseInfo{TagName: "v2.2.5"}, true},
+		{"minor allows minor", config.UpdateConfig{Policy: "minor"}, "v2.2.5", ReleaseInfo{TagName: "v2.3.0"}, false},
+		{"minor rejects major", config.UpdateConfig{Policy: "minor"}, "v2.2.5", ReleaseInfo{TagName: "v3.0.0"}, true},
+		{"patch allows patch", config.UpdateConfig{Policy: "patch"}, "v2.2.5", ReleaseInfo{TagName: "v2.2.6"}, false},
+		{"patch rejects minor", config.UpdateConfig{Policy: "patch"}, "v2.2.5", ReleaseInfo{TagName: "v2.3.0"}, true},
+		{"pre-release flag rejected", config.UpdateConfig{}, "v2.2.5", ReleaseInfo{TagName: "v2.3.0", Prerelease: true}, true},
+		{"pre-release tag rejected", config.UpdateConfig{}, "v2.2.5", ReleaseInfo{TagName: "v2.3.0-beta.1"}, true},
+		{"pre-release allowed", config.UpdateConfig{Prerelease: true}, "v2.2.5", ReleaseInfo{TagName: "v2.3.0-beta.1"}, false},
+		{"release after its pre-release", config.UpdateConfig{Prerelease: true}, "v2.3.0-beta.1", ReleaseInfo{TagName: "v2.3.0"}, false},
+		{"fresh install ignores policy", config.UpdateConfig{Policy: "patch"}, "", ReleaseInfo{TagName: "v3.0.0"}, false},
+		{"fresh install still rejects pre-release", config.UpdateConfig{}, "", ReleaseInfo{TagName: "v3.0.0-rc.1"}, true},
+		{"pin allows downgrade", config.UpdateConfig{Pin: "v2.0.0", Policy: "patch"}, "v2.2.5", ReleaseInfo{TagName: "v2.0.0"}, false},
+		{"unparsable tag treated as upgrade", config.UpdateConfig{Policy: "patch"}, "v2.2.5", ReleaseInfo{TagName: "nightly"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := NewInstaller("", tt.policy, "cfst", t.TempDir())
+			if reason := i.rejectByPolicy(tt.current, &tt.next); (reason != "") != tt.reject {
+				t.Errorf("rejectByPolicy(%q, %s) = %q, want reject %v", tt.current, tt.next.TagName, reason, tt.reject)
+			}
+		})
+	}
+}
+
+func TestReleaseURL(t *testing.T) {
+	const latest = "https://api.github.com/repos/XIU2/CloudflareSpeedTest/releases/latest"
+	tests := []struct {
+		name     string
+		policy   config.UpdateConfig
+		wantURL  string
+		wantList bool
+	}{
+		{"latest", config.UpdateConfig{ApiURL: latest}, latest, false},
+		{"pin", config.UpdateConfig{ApiURL: latest, Pin: "v2.2.5", Prerelease: true},
+			"https://api.github.com/repos/XIU2/CloudflareSpeedTest/releases/tags/v2.2.5", false},
+		{"prerelease lists releases", config.UpdateConfig{ApiURL: latest + "/", Prerelease: true},
+			"https://api.github.com/repos/XIU2/CloudflareSpeedTest/releases?per_page=30", true},
+		{"custom url kept", config.UpdateConfig{ApiURL: "https://mirror.example/cfst.json", Prerelease: true},
+			"https://mirror.example/cfst.json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := NewInstaller("", tt.policy, "cfst", t.TempDir())
+			if u, list := i.releaseURL(); u != tt.wantURL || list != tt.wantList {
+				t.Errorf("releaseURL() = %s, %v, want %s, %v", u, list, tt.wantURL, tt.wantList)
+			}
+		})
+	}
+}
+
+func TestPickRelease(t *testing.T) {
+	releases := []ReleaseInfo{
+		{TagName: "v3.0.0-beta.2", Draft: true},
+		{TagName: "v3.0.0-beta.1", Prerelease: true},
+		{TagName: "v2.2.7-rc.1", Prerelease: true},
+		{TagName: "v2.2.6"},
+		{TagName: "v2.2.5"},
+	}
+	tests := []struct {
+		policy  string
+		current string
+		want    string
+	}{
+		{"any", "v2.2.5", "v3.0.0-beta.1"},
+		{"patch", "v2.2.5", "v2.2.7-rc.1"},
+		{"patch", "v2.2.7-rc.1", "v3.0.0-beta.1"}, // 没有符合策略的新版本时返回最高版本，由调用方记录原因
+	}
+	for _, tt := range tests {
+		i := NewInstaller("", config.UpdateConfig{Policy: tt.policy, Prerelease: true}, "cfst", t.TempDir())
+		got := i.pickRelease(tt.current, releases)
+		if got == nil || got.TagName != tt.want {
+			t.Errorf("pickRelease(%s, %s) = %+v, want %s", tt.policy, tt.current, got, tt.want)
+		}
+	}
+	i := NewInstaller("", config.UpdateConfig{Prerelease: true}, "cfst", t.TempDir())
+	if got := i.pickRelease("", []ReleaseInfo{{TagName: "nightly", Draft: true}, {TagName: "weekly"}}); got == nil || got.TagName != "weekly" {
+		t.Errorf("pickRelease without semver tags = %+v, want weekly", got)
+	}
+}
@@ -0,0 +1,144 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// findChecksumAsset 查找 Release 中的校验文件，name 为空时自动查找名称包含 checksum 或 sha256 的文件
+func findChecksumAsset(assets []ReleaseAsset, name string) *ReleaseAsset {
+	for idx := range assets {
+		a := &assets[idx]
+		lower := strings.ToLower(a.Name)
+		if strings.HasSuffix(lower, ".sig") {
+			continue
+		}
+		if name != "" {
+			if a.Name == name {
+				return a
+			}
+			continue
+		}
+		if strings.Contains(lower, "checksum") || strings.Contains(lower, "sha256") {
+			return a
+		}
+	}
+	return nil
+}
+
+// parseChecksums 解析 sha256sum 格式的校验文件（"<hash>  <filename>"，文件名可带 * 前缀）
+func parseChecksums(data []byte) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || len(fields[0]) != 64 {
+			continue
+		}
+		if _, err := hex.DecodeString(fields[0]); err != nil {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums
+}
+
+// verifySignature 使用 ed25519 公钥校验签名，签名文件可以是原始 64 字节或 Base64 编码
+func verifySignature(publicKey string, data, sig []byte) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid ed25519 public key")
+	}
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return fmt.Errorf("signature is neither raw nor base64 encoded: %w", err)
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+// verifyArchive 校验下载的压缩包哈希。依次检查配置中固定的 SHA-256 列表和
+// Release 提供的校验文件（设置了公钥时还会校验其签名），任一不匹配都会拒绝安装。
+// Release 信息或校验文件经过代理镜像获取时（releaseProxied 或 fetch 回退到代理），
+// 未签名的校验文件可能被镜像伪造，只用于发现不匹配，不算作通过校验。
+func (i *Installer) verifyArchive(info *ReleaseInfo, releaseProxied bool, assetName, sum string) error {
+	verified := false
+
+	if pinned := i.verify.SHA256; len(pinned) > 0 {
+		matched := false
+		for _, p := range pinned {
+			if strings.EqualFold(p, sum) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("sha256 %s of %s is not in the pinned update.verify.sha256 list", sum, assetName)
+		}
+		log.Printf("SHA-256 of %s matches the pinned list.", assetName)
+		verified = true
+	}
+
+	if ca := findChecksumAsset(info.Assets, i.verify.ChecksumAsset); ca != nil {
+		data, proxied, err := i.fetch(ca.BrowserDownloadURL)
+		if err != nil {
+			return fmt.Errorf("download checksum file %s: %w", ca.Name, err)
+		}
+		trusted := !releaseProxied && !proxied
+		if i.verify.PublicKey != "" {
+			sigAsset := findAsset(info.Assets, ca.Name+".sig")
+			if sigAsset == nil {
+				return fmt.Errorf("signature %s.sig not found in release assets", ca.Name)
+			}
+			sig, _, err := i.fetch(sigAsset.BrowserDownloadURL)
+			if err != nil {
+				return fmt.Errorf("download signature %s: %w", sigAsset.Name, err)
+			}
+			if err := verifySignature(i.verify.PublicKey, data, sig); err != nil {
+				return fmt.Errorf("verify signature of %s: %w", ca.Name, err)
+			}
+			log.Printf("Signature of checksum file %s is valid.", ca.Name)
+			trusted = true
+		}
+		expected, ok := parseChecksums(data)[assetName]
+		if !ok {
+			return fmt.Errorf("checksum file %s has no entry for %s", ca.Name, assetName)
+		}
+		if expected != sum {
+			return fmt.Errorf("sha256 mismatch for %s: expected %s, got %s", assetName, expected, sum)
+		}
+		if trusted {
+			log.Printf("SHA-256 of %s matches checksum file %s.", assetName, ca.Name)
+			verified = true
+		} else {
+			log.Printf("WARN: Checksum file %s was obtained through proxy_prefix and is not signed; its match does not count as verification.", ca.Name)
+		}
+	} else if i.verify.ChecksumAsset != "" {
+		return fmt.Errorf("checksum file %s not found in release assets", i.verify.ChecksumAsset)
+	} else if i.verify.PublicKey != "" {
+		return fmt.Errorf("a public key is configured but the release has no checksum file")
+	}
+
+	if !verified {
+		if i.verify.Require {
+			return fmt.Errorf("no trusted checksum available for %s and update.verify.require is enabled", assetName)
+		}
+		log.Printf("WARN: ************************************************************")
+		log.Printf("WARN: %s could NOT be verified: no pinned sha256 and no trusted checksum file.", assetName)
+		log.Printf("WARN: Installing an UNVERIFIED archive (sha256 %s).", sum)
+		log.Printf("WARN: Set update.verify.sha256 or update.verify.require to refuse unverified updates.")
+		log.Printf("WARN: ************************************************************")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package installer
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+const (
+	sumA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	sumB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+func TestParseChecksums(t *testing.T) {
+	data := strings.Join([]string{
+		sumA + "  cfst_linux_amd64.tar.gz",
+		strings.ToUpper(sumB) + " *cfst_windows_amd64.zip",
+		"",
+		"# comment line",
+		"abc123  short_hash.tar.gz",
+		strings.Repeat("z", 64) + "  not_hex.tar.gz",
+		sumA + "  too  many_fields.tar.gz",
+	}, "\n")
+
+	got := parseChecksums([]byte(data))
+	want := map[string]string{
+		"cfst_linux_amd64.tar.gz": sumA,
+		"cfst_windows_amd64.zip":  sumB,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseChecksums = %v, want %v", got, want)
+	}
+	for name, sum := range want {
+		if got[name] != sum {
+			t.Errorf("checksum of %s = %q, want %q", name, got[name], sum)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	data := []byte(sumA + "  cfst.tar.gz\n")
+	sig := ed25519.Sign(priv, data)
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	tests := []struct {
+		name    string
+		key     string
+		data    []byte
+		sig     []byte
+		wantErr string
+	}{
+		{"raw signature", key, data, sig, ""},
+		{"base64 signature", key, data, []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), ""},
+		{"tampered data", key, []byte(sumB + "  cfst.tar.gz\n"), sig, "does not match"},
+		{"wrong key", base64.StdEncoding.EncodeToString(otherPub), data, sig, "does not match"},
+		{"invalid key", "not-a-key", data, sig, "invalid ed25519 public key"},
+		{"garbage signature", key, data, []byte("not a signature"), "neither raw nor base64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.key, tt.data, tt.sig)
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVerifyArchive(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksums := []byte(sumA + "  cfst_linux_amd64.tar.gz\n")
+	files := map[string][]byte{
+		"/checksums.txt":     checksums,
+		"/checksums.txt.sig": ed25519.Sign(priv, checksums),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	withChecksums := []ReleaseAsset{
+		{Name: "cfst_linux_amd64.tar.gz", BrowserDownloadURL: srv.URL + "/cfst_linux_amd64.tar.gz"},
+		{Name: "checksums.txt", BrowserDownloadURL: srv.URL + "/checksums.txt"},
+		{Name: "checksums.txt.sig", BrowserDownloadURL: srv.URL + "/checksums.txt.sig"},
+	}
+	withoutChecksums := withChecksums[:1]
+
+	tests := []struct {
+		name      string
+		verify    config.UpdateVerifyConfig
+		assets    []ReleaseAsset
+		proxied   bool
+		assetName string
+		sum       string
+		wantErr   string
+	}{
+		{"checksum file matches", config.UpdateVerifyConfig{}, withChecksums, false, "cfst_linux_amd64.tar.gz", sumA, ""},
+		{"hash mismatch", config.UpdateVerifyConfig{}, withChecksums, false, "cfst_linux_amd64.tar.gz", sumB, "sha256 mismatch"},
+		{"missing checksum entry", config.UpdateVerifyConfig{}, withChecksums, false, "cfst_darwin_arm64.zip", sumA, "has no entry for cfst_darwin_arm64.zip"},
+		{"pinned sha256 matches", config.UpdateVerifyConfig{SHA256: []string{strings.ToUpper(sumA)}, Require: true}, withoutChecksums, false, "cfst_linux_amd64.tar.gz", sumA, ""},
+		{"pinned sha256 mismatch", config.UpdateVerifyConfig{SHA256: []string{sumB}}, withChecksums, false, "cfst_linux_amd64.tar.gz", sumA, "not in the pinned"},
+		{"signed checksum file", config.UpdateVerifyConfig{PublicKey: base64.StdEncoding.EncodeToString(pub), Require: true}, withChecksums, true, "cfst_linux_amd64.tar.gz", sumA, ""},
+		{"bad signature", config.UpdateVerifyConfig{PublicKey: base64.StdEncoding.EncodeToString(make([]byte, ed25519.PublicKeySize))}, withChecksums, false, "cfst_linux_amd64.tar.gz", sumA, "verify signature"},
+		{"signature asset missing", config.UpdateVerifyConfig{PublicKey: base64.StdEncoding.EncodeToString(pub)}, withChecksums[:2], false, "cfst_linux_amd64.tar.gz", sumA, "checksums.txt.sig not found"},
+		{"named checksum asset missing", config.UpdateVerifyConfig{ChecksumAsset: "SHA256SUMS"}, withChecksums, false, "cfst_linux_amd64.tar.gz", sumA, "SHA256SUMS not found"},
+		{"require without checksum asset", config.UpdateVerifyConfig{Require: true}, withoutChecksums, false, "cfst_linux_amd64.tar.gz", sumA, "update.verify.require"},
+		{"unverified allowed without require", config.UpdateVerifyConfig{}, withoutChecksums, false, "cfst_linux_amd64.tar.gz", sumA, ""},
+		{"unsigned checksum via proxy is not trusted", config.UpdateVerifyConfig{Require: true}, withChecksums, true, "cfst_linux_amd64.tar.gz", sumA, "update.verify.require"},
+		{"unsigned checksum via proxy still catches mismatch", config.UpdateVerifyConfig{}, withChecksums, true, "cfst_linux_amd64.tar.gz", sumB, "sha256 mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := NewInstaller("", config.UpdateConfig{Verify: tt.verify}, "cfst", t.TempDir())
+			err := i.verifyArchive(&ReleaseInfo{TagName: "v2.0.0", Assets: tt.assets}, tt.proxied, tt.assetName, tt.sum)
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFetchFallsBackToProxy(t *testing.T) {
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "blocked", http.StatusForbidden)
+	}))
+	defer direct.Close()
+	var proxiedPath string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedPath = r.URL.Path
+		w.Write([]byte("release"))
+	}))
+	defer proxy.Close()
+
+	i := NewInstaller(proxy.URL+"/", config.UpdateConfig{}, "cfst", t.TempDir())
+	data, proxied, err := i.fetch(direct.URL + "/releases/latest")
+	if err != nil || !proxied || string(data) != "release" {
+		t.Fatalf("fetch = %q, %v, %v", data, proxied, err)
+	}
+	if !strings.HasSuffix(proxiedPath, "/releases/latest") {
+		t.Errorf("proxy received path %q", proxiedPath)
+	}
+
+	i = NewInstaller("", config.UpdateConfig{}, "cfst", t.TempDir())
+	if _, proxied, err := i.fetch(direct.URL + "/releases/latest"); err == nil || proxied {
+		t.Errorf("fetch without proxy = %v, %v, want direct error", proxied, err)
+	}
+}
+
+// checkErr 检查 err 是否包含 want，want 为空时要求没有错误
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want containing %q", err, want)
+	}
+}
@@ -0,0 +1,34 @@
+package metrics
+
+// Default 是程序使用的全局指标注册表
+var Default = &Registry{}
+
+var (
+	// Runs 统计完整测试（runAllTests）的执行次数
+	Runs = NewCounterVec(Default, "cfst_client_runs_total",
+		"Total number of full test runs started.")
+	// Attempts 统计每个 IP 版本的测速尝试次数
+	Attempts = NewCounterVec(Default, "cfst_client_attempts_total",
+		"Total number of speed-test attempts.", "ip_version")
+	// Failures 统计即时重试全部失败的次数
+	Failures = NewCounterVec(Default, "cfst_client_failures_total",
+		"Total number of tests that produced no results after all immediate retries.", "ip_version")
+	// Uploads 统计上传到各存储目标的次数及结果
+	Uploads = NewCounterVec(Default, "cfst_client_uploads_total",
+		"Total number of result uploads by storage target and result.", "ip_version", "storage", "result")
+	// CfstDuration 记录每次测速尝试的耗时，包括 cfst 和 native 两种引擎（名称保留以兼容已有的面板）
+	CfstDuration = NewHistogram(Default, "cfst_client_cfst_duration_seconds",
+		"Duration of speed-test attempts in seconds, for both the cfst and native engines.",
+		[]float64{30, 60, 120, 180, 300, 600, 900, 1200, 1800, 3600})
+
+	resultLabels = []string{"ip_version", "device_name", "line_operator"}
+	// BestLatency 记录最近一次测试中最优 IP 的平均延迟（秒）
+	BestLatency = NewGaugeVec(Default, "cfst_client_best_latency_seconds",
+		"Average latency in seconds of the best IP in the last successful test.", resultLabels...)
+	// BestLoss 记录最近一次测试中最优 IP 的丢包率（0-1）
+	BestLoss = NewGaugeVec(Default, "cfst_client_best_loss_ratio",
+		"Packet loss ratio (0-1) of the best IP in the last successful test.", resultLabels...)
+	// BestSpeed 记录最近一次测试中最优 IP 的下载速度（字节/秒）
+	BestSpeed = NewGaugeVec(Default, "cfst_client_best_download_bytes_per_second",
+		"Download speed in bytes per second of the best IP in the last successful test.", resultLabels...)
+)
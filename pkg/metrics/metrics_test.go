@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpose(t *testing.T) {
+	r := &Registry{}
+	NewCounterVec(r, "test_runs_total", "Total runs.")
+	uploads := NewCounterVec(r, "test_uploads_total", "Uploads by target.", "storage", "result")
+	speed := NewGaugeVec(r, "test_speed_bytes_per_second", "Speed with a \\ backslash\nand newline.", "device_name")
+	hist := NewHistogram(r, "test_duration_seconds", "Duration.", []float64{1, 5})
+
+	uploads.Inc("s3", "success")
+	uploads.Inc("gist", "failure")
+	uploads.Inc("s3", "success")
+	speed.Set(12.5*1024*1024, "客厅 \"NAS\"\\1\n")
+	hist.Observe(0.5)
+	hist.Observe(3)
+	hist.Observe(10)
+
+	var sb strings.Builder
+	r.Expose(&sb)
+
+	want := `# HELP test_runs_total Total runs.
+# TYPE test_runs_total counter
+test_runs_total 0
+# HELP test_uploads_total Uploads by target.
+# TYPE test_uploads_total counter
+test_uploads_total{storage="gist",result="failure"} 1
+test_uploads_total{storage="s3",result="success"} 2
+# HELP test_speed_bytes_per_second Speed with a \\ backslash\nand newline.
+# TYPE test_speed_bytes_per_second gauge
+test_speed_bytes_per_second{device_name="客厅 \"NAS\"\\1\n"} 1.31072e+07
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="1"} 1
+test_duration_seconds_bucket{le="5"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 13.5
+test_duration_seconds_count 3
+`
+	if got := sb.String(); got != want {
+		t.Errorf("Expose() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,11 +1,18 @@
 package models
 
+import "encoding/json"
+
 // GistContent 是上传到 Gist 的 JSON 文件的完整结构体
+// [修改] 增加 schema_version，旧版格式不包含该字段
 type GistContent struct {
-	Timestamp string         `json:"timestamp"`
-	Results   []DeviceResult `json:"results"`
+	SchemaVersion int            `json:"schema_version,omitempty"`
+	Timestamp     string         `json:"timestamp"`
+	Results       []DeviceResult `json:"results"`
 }
 
+// SchemaExtended 是包含扩展测速字段（sent、received、avg_latency_ms、measured_at）的结果格式版本
+const SchemaExtended = 2
+
 // DeviceResult 代表单条测速结果
 // [修改] 调整 json 标签以从最终 json 中排除某些字段
 type DeviceResult struct {
@@ -13,7 +20,59 @@ type DeviceResult struct {
 	Operator  string  `json:"-"` // 在 JSON 序列化时忽略此字段
 	IP        string  `json:"ip"`
 	LatencyMs int     `json:"latency_ms"`
-	LossPct   float64 `json:"loss_pct"`
+	LossPct   float64 `json:"loss_pct"` // 丢包率，与 CloudflareSpeedTest 输出一致为 0-1 的比例而非百分数
 	DLMBps    float64 `json:"dl_mbps"`
 	Region    string  `json:"region"`
-}
\ No newline at end of file
+	// [新增] 扩展字段，仅在 schema_version >= 2 时上传
+	Sent         int     `json:"sent,omitempty"`           // 已发送的延迟测试包数
+	Received     int     `json:"received,omitempty"`       // 已接收的延迟测试包数
+	AvgLatencyMs float64 `json:"avg_latency_ms,omitempty"` // 未取整的平均延迟
+	MeasuredAt   string  `json:"measured_at,omitempty"`    // 测速完成时间（RFC3339）
+}
+
+// Latency 返回用于排序和比较的延迟（毫秒），有未取整的平均延迟时优先使用
+func (r DeviceResult) Latency() float64 {
+	if r.AvgLatencyMs > 0 {
+		return r.AvgLatencyMs
+	}
+	return float64(r.LatencyMs)
+}
+
+// Basic 返回去掉扩展字段的副本，用于按旧格式上传
+func (r DeviceResult) Basic() DeviceResult {
+	r.Sent, r.Received, r.AvgLatencyMs, r.MeasuredAt = 0, 0, 0, ""
+	return r
+}
+
+// extendedResult 与 DeviceResult 字段相同，但扩展字段不省略零值，
+// 使 schema_version 2 中的 received: 0 等计数能被读取方区分于缺失
+type extendedResult struct {
+	Device       string  `json:"-"`
+	Operator     string  `json:"-"`
+	IP           string  `json:"ip"`
+	LatencyMs    int     `json:"latency_ms"`
+	LossPct      float64 `json:"loss_pct"`
+	DLMBps       float64 `json:"dl_mbps"`
+	Region       string  `json:"region"`
+	Sent         int     `json:"sent"`
+	Received     int     `json:"received"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MeasuredAt   string  `json:"measured_at,omitempty"`
+}
+
+// MarshalJSON 在 schema_version >= 2 时输出全部扩展字段，旧格式保持原样
+func (c GistContent) MarshalJSON() ([]byte, error) {
+	type plain GistContent
+	if c.SchemaVersion < SchemaExtended {
+		return json.Marshal(plain(c))
+	}
+	results := make([]extendedResult, len(c.Results))
+	for i, r := range c.Results {
+		results[i] = extendedResult(r)
+	}
+	return json.Marshal(struct {
+		SchemaVersion int              `json:"schema_version"`
+		Timestamp     string           `json:"timestamp"`
+		Results       []extendedResult `json:"results"`
+	}{c.SchemaVersion, c.Timestamp, results})
+}
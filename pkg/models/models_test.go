@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExtendedSchemaKeepsZeroCounters(t *testing.T) {
+	content := GistContent{
+		SchemaVersion: SchemaExtended,
+		Timestamp:     "2026-01-02T03:04:05Z",
+		Results:       []DeviceResult{{IP: "1.1.1.1", Sent: 4, Received: 0, Device: "dev"}},
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"schema_version":2`, `"sent":4`, `"received":0`, `"avg_latency_ms":0`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s missing %s", data, want)
+		}
+	}
+	if strings.Contains(string(data), "dev") {
+		t.Errorf("%s contains the device name", data)
+	}
+
+	var back GistContent
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.SchemaVersion != SchemaExtended || back.Results[0].Sent != 4 {
+		t.Errorf("round trip = %+v", back)
+	}
+}
+
+func TestBasicSchemaOmitsExtendedFields(t *testing.T) {
+	content := GistContent{
+		Timestamp: "2026-01-02T03:04:05Z",
+		Results:   []DeviceResult{DeviceResult{IP: "1.1.1.1", Sent: 4, AvgLatencyMs: 1.5}.Basic()},
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unwanted := range []string{"schema_version", "sent", "received", "avg_latency_ms", "measured_at"} {
+		if strings.Contains(string(data), unwanted) {
+			t.Errorf("%s contains %s", data, unwanted)
+		}
+	}
+}
+
+func TestLatencyPrefersAverage(t *testing.T) {
+	if got := (DeviceResult{LatencyMs: 12, AvgLatencyMs: 12.7}).Latency(); got != 12.7 {
+		t.Errorf("Latency() = %v, want 12.7", got)
+	}
+	if got := (DeviceResult{LatencyMs: 12}).Latency(); got != 12 {
+		t.Errorf("Latency() = %v, want 12", got)
+	}
+}
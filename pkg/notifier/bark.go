@@ -0,0 +1,64 @@
+// File: pkg/notifier/bark.go
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// BarkNotifier 实现了 Bark (iOS) 推送通知
+type BarkNotifier struct {
+	cfg        config.BarkConfig
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewBarkNotifier 创建一个新的 Bark 通知器实例
+func NewBarkNotifier(cfg config.BarkConfig) *BarkNotifier {
+	base := cfg.APIURL
+	if base == "" {
+		base = "https://api.day.app"
+	}
+	return &BarkNotifier{
+		cfg:        cfg,
+		apiURL:     strings.TrimRight(base, "/") + "/push",
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Notify 发送通知
+func (b *BarkNotifier) Notify(title, message string) error {
+	payload := map[string]string{
+		"device_key": b.cfg.DeviceKey,
+		"title":      title,
+		"body":       message,
+	}
+	if b.cfg.Group != "" {
+		payload["group"] = b.cfg.Group
+	}
+	if b.cfg.Sound != "" {
+		payload["sound"] = b.cfg.Sound
+	}
+	if b.cfg.Icon != "" {
+		payload["icon"] = b.cfg.Icon
+	}
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := postJSON(b.httpClient, b.apiURL, payload, &result); err != nil {
+		return fmt.Errorf("failed to send bark notification: %w", err)
+	}
+	if result.Code != 200 {
+		return fmt.Errorf("bark notification failed with code %d: %s", result.Code, result.Message)
+	}
+
+	log.Println("Bark notification sent successfully.")
+	return nil
+}
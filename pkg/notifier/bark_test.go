@@ -0,0 +1,32 @@
+package notifier
+
+import (
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+func TestBarkNotify(t *testing.T) {
+	srv, got := newCaptureServer(t, `{"code":200,"message":"success"}`)
+	b := NewBarkNotifier(config.BarkConfig{DeviceKey: "dev", Group: "cfst", APIURL: srv.URL + "/"})
+	if err := b.Notify("title", "message"); err != nil {
+		t.Fatal(err)
+	}
+	if got.path != "/push" {
+		t.Errorf("path = %s, want /push", got.path)
+	}
+	for k, v := range map[string]string{"device_key": "dev", "title": "title", "body": "message", "group": "cfst"} {
+		if got.body[k] != v {
+			t.Errorf("%s = %v, want %q", k, got.body[k], v)
+		}
+	}
+	if _, ok := got.body["sound"]; ok {
+		t.Error("unset sound should be omitted")
+	}
+}
+
+func TestBarkNotifyReportsErrorCode(t *testing.T) {
+	srv, _ := newCaptureServer(t, `{"code":400,"message":"failed to get device token"}`)
+	b := NewBarkNotifier(config.BarkConfig{DeviceKey: "bad", APIURL: srv.URL})
+	wantErrorCode(t, b.Notify("title", "message"), "400")
+}
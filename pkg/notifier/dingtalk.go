@@ -0,0 +1,77 @@
+// File: pkg/notifier/dingtalk.go
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// DingTalkNotifier 实现了钉钉自定义机器人通知
+type DingTalkNotifier struct {
+	accessToken string
+	secret      string
+	apiURL      string
+	httpClient  *http.Client
+}
+
+// NewDingTalkNotifier 创建一个新的钉钉通知器实例
+func NewDingTalkNotifier(cfg config.DingTalkConfig) *DingTalkNotifier {
+	base := cfg.APIURL
+	if base == "" {
+		base = "https://oapi.dingtalk.com"
+	}
+	return &DingTalkNotifier{
+		accessToken: cfg.AccessToken,
+		secret:      cfg.Secret,
+		apiURL:      strings.TrimRight(base, "/") + "/robot/send",
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// dingTalkSign 按钉钉加签规则计算签名：Base64(HMAC-SHA256(secret, timestamp+"\n"+secret))
+func dingTalkSign(secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d\n%s", timestamp, secret)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Notify 以 Markdown 消息发送通知
+func (d *DingTalkNotifier) Notify(title, message string) error {
+	query := url.Values{"access_token": {d.accessToken}}
+	if d.secret != "" {
+		timestamp := time.Now().UnixMilli()
+		query.Set("timestamp", strconv.FormatInt(timestamp, 10))
+		query.Set("sign", dingTalkSign(d.secret, timestamp))
+	}
+
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": title,
+			"text":  fmt.Sprintf("### %s\n\n%s", title, strings.ReplaceAll(message, "\n", "\n\n")),
+		},
+	}
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := postJSON(d.httpClient, d.apiURL+"?"+query.Encode(), payload, &result); err != nil {
+		return fmt.Errorf("failed to send dingtalk notification: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("dingtalk notification failed with code %d: %s", result.ErrCode, result.ErrMsg)
+	}
+
+	log.Println("DingTalk notification sent successfully.")
+	return nil
+}
@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+func TestDingTalkNotifySignsQuery(t *testing.T) {
+	srv, got := newCaptureServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	d := NewDingTalkNotifier(config.DingTalkConfig{AccessToken: "tok", Secret: "SECxyz", APIURL: srv.URL})
+	before := time.Now().UnixMilli()
+	if err := d.Notify("title", "message"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.path != "/robot/send" || got.query.Get("access_token") != "tok" {
+		t.Errorf("request = %s?%s", got.path, got.query.Encode())
+	}
+	ts, err := strconv.ParseInt(got.query.Get("timestamp"), 10, 64)
+	if err != nil || ts < before || ts > time.Now().UnixMilli() {
+		t.Fatalf("timestamp = %q, want current time in milliseconds", got.query.Get("timestamp"))
+	}
+	mac := hmac.New(sha256.New, []byte("SECxyz"))
+	mac.Write([]byte(got.query.Get("timestamp") + "\nSECxyz"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); got.query.Get("sign") != want {
+		t.Errorf("sign = %q, want %q", got.query.Get("sign"), want)
+	}
+	if got.body["msgtype"] != "markdown" {
+		t.Errorf("msgtype = %v", got.body["msgtype"])
+	}
+}
+
+func TestDingTalkNotifyWithoutSecret(t *testing.T) {
+	srv, got := newCaptureServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	d := NewDingTalkNotifier(config.DingTalkConfig{AccessToken: "tok", APIURL: srv.URL})
+	if err := d.Notify("title", "message"); err != nil {
+		t.Fatal(err)
+	}
+	if got.query.Has("sign") || got.query.Has("timestamp") {
+		t.Errorf("unsigned request has query %s", got.query.Encode())
+	}
+}
+
+func TestDingTalkNotifyReportsErrorCode(t *testing.T) {
+	srv, _ := newCaptureServer(t, `{"errcode":310000,"errmsg":"sign not match"}`)
+	d := NewDingTalkNotifier(config.DingTalkConfig{AccessToken: "tok", Secret: "wrong", APIURL: srv.URL})
+	wantErrorCode(t, d.Notify("title", "message"), "310000")
+}
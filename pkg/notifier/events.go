@@ -0,0 +1,117 @@
+// File: pkg/notifier/events.go
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+// EventType 标识触发通知的运行事件类型
+type EventType string
+
+const (
+	EventSuccess       EventType = "success"
+	EventFailure       EventType = "failure"
+	EventDelayedRetry  EventType = "delayed_retry"
+	EventUploadFailure EventType = "upload_failure"
+	EventUpdate        EventType = "update"
+	EventShutdown      EventType = "shutdown"
+)
+
+// Event 描述一次需要发送通知的运行事件
+type Event struct {
+	Type      EventType
+	Title     string
+	Message   string
+	Device    string
+	Operator  string
+	IPVersion string
+	Results   []models.DeviceResult
+	Time      time.Time
+}
+
+// EventNotifier 可由需要结构化事件数据的通知器实现，
+// Dispatcher 会优先调用 NotifyEvent 而不是 Notify。
+type EventNotifier interface {
+	Notifier
+	NotifyEvent(ev Event) error
+}
+
+// Dispatcher 按照事件开关将事件分发给所有已配置的通知器
+type Dispatcher struct {
+	notifiers []Notifier
+	events    config.NotificationEventsConfig
+}
+
+// NewDispatcher 创建一个新的事件分发器
+func NewDispatcher(events config.NotificationEventsConfig, notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{
+		notifiers: notifiers,
+		events:    events,
+	}
+}
+
+// Enabled 判断某类事件是否需要发送通知
+func (d *Dispatcher) Enabled(t EventType) bool {
+	if d == nil || len(d.notifiers) == 0 {
+		return false
+	}
+	switch t {
+	case EventSuccess:
+		return d.events.Success
+	case EventFailure:
+		return d.events.Failure
+	case EventDelayedRetry:
+		return d.events.DelayedRetry
+	case EventUploadFailure:
+		return d.events.UploadFailure
+	case EventUpdate:
+		return d.events.Update
+	case EventShutdown:
+		return d.events.Shutdown
+	}
+	return false
+}
+
+// Dispatch 将事件发送给所有通知器，单个通知器失败不会影响其他通知器
+func (d *Dispatcher) Dispatch(ev Event) {
+	if !d.Enabled(ev.Type) {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	for _, n := range d.notifiers {
+		var err error
+		if en, ok := n.(EventNotifier); ok {
+			err = en.NotifyEvent(ev)
+		} else {
+			err = n.Notify(ev.Title, ev.Message)
+		}
+		if err != nil {
+			log.Printf("WARN: Failed to send %s notification via %T: %v", ev.Type, n, err)
+		}
+	}
+}
+
+// FormatResults 将前 n 条结果格式化为适合通知展示的文本
+func FormatResults(results []models.DeviceResult, n int) string {
+	if n > len(results) {
+		n = len(results)
+	}
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		r := results[i]
+		fmt.Fprintf(&sb, "%d. %s  %dms  %.2fMB/s  loss %.2f", i+1, r.IP, r.LatencyMs, r.DLMBps, r.LossPct)
+		if r.Region != "" {
+			fmt.Fprintf(&sb, "  %s", r.Region)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+// recordingNotifier 记录收到的通知，err 不为空时每次调用都返回该错误
+type recordingNotifier struct {
+	titles []string
+	err    error
+}
+
+func (n *recordingNotifier) Notify(title, message string) error {
+	n.titles = append(n.titles, title)
+	return n.err
+}
+
+// recordingEventNotifier 同时实现 EventNotifier，分别记录两种调用
+type recordingEventNotifier struct {
+	recordingNotifier
+	events []Event
+}
+
+func (n *recordingEventNotifier) NotifyEvent(ev Event) error {
+	n.events = append(n.events, ev)
+	return n.err
+}
+
+func TestDispatcherEnabled(t *testing.T) {
+	all := config.NotificationEventsConfig{Success: true, Failure: true, DelayedRetry: true, UploadFailure: true, Update: true, Shutdown: true}
+	types := []EventType{EventSuccess, EventFailure, EventDelayedRetry, EventUploadFailure, EventUpdate, EventShutdown}
+	n := &recordingNotifier{}
+
+	d := NewDispatcher(all, n)
+	for _, typ := range types {
+		if !d.Enabled(typ) {
+			t.Errorf("Enabled(%s) = false with every event on", typ)
+		}
+	}
+	if d.Enabled("unknown") {
+		t.Error("Enabled(unknown) = true")
+	}
+
+	// 每个开关只影响对应的事件
+	switches := []func(*config.NotificationEventsConfig){
+		func(c *config.NotificationEventsConfig) { c.Success = false },
+		func(c *config.NotificationEventsConfig) { c.Failure = false },
+		func(c *config.NotificationEventsConfig) { c.DelayedRetry = false },
+		func(c *config.NotificationEventsConfig) { c.UploadFailure = false },
+		func(c *config.NotificationEventsConfig) { c.Update = false },
+		func(c *config.NotificationEventsConfig) { c.Shutdown = false },
+	}
+	for i, off := range switches {
+		events := all
+		off(&events)
+		d := NewDispatcher(events, n)
+		for j, typ := range types {
+			if got := d.Enabled(typ); got != (i != j) {
+				t.Errorf("with %s off: Enabled(%s) = %v", types[i], typ, got)
+			}
+		}
+	}
+
+	var nilDispatcher *Dispatcher
+	if nilDispatcher.Enabled(EventSuccess) {
+		t.Error("nil dispatcher reports events as enabled")
+	}
+	if NewDispatcher(all).Enabled(EventSuccess) {
+		t.Error("dispatcher without notifiers reports events as enabled")
+	}
+}
+
+func TestDispatchSkipsDisabledEvents(t *testing.T) {
+	n := &recordingNotifier{}
+	d := NewDispatcher(config.NotificationEventsConfig{Failure: true}, n)
+	d.Dispatch(Event{Type: EventSuccess, Title: "ok"})
+	d.Dispatch(Event{Type: EventFailure, Title: "failed"})
+	if len(n.titles) != 1 || n.titles[0] != "failed" {
+		t.Errorf("notified %v, want only the failure event", n.titles)
+	}
+
+	// nil 或没有通知器的分发器不能 panic
+	var nilDispatcher *Dispatcher
+	nilDispatcher.Dispatch(Event{Type: EventFailure})
+	NewDispatcher(config.NotificationEventsConfig{Failure: true}).Dispatch(Event{Type: EventFailure})
+}
+
+func TestDispatchPrefersNotifyEvent(t *testing.T) {
+	en := &recordingEventNotifier{}
+	d := NewDispatcher(config.NotificationEventsConfig{Success: true}, en)
+	d.Dispatch(Event{Type: EventSuccess, Title: "ok", IPVersion: "v6"})
+	if len(en.titles) != 0 {
+		t.Errorf("Notify called with %v, want NotifyEvent only", en.titles)
+	}
+	if len(en.events) != 1 || en.events[0].IPVersion != "v6" {
+		t.Fatalf("NotifyEvent received %+v", en.events)
+	}
+	if en.events[0].Time.IsZero() {
+		t.Error("event time was not filled in")
+	}
+}
+
+func TestDispatchContinuesAfterError(t *testing.T) {
+	failing := &recordingNotifier{err: errors.New("boom")}
+	failingEvent := &recordingEventNotifier{recordingNotifier: recordingNotifier{err: errors.New("boom")}}
+	ok := &recordingNotifier{}
+	d := NewDispatcher(config.NotificationEventsConfig{Failure: true}, failing, failingEvent, ok)
+	d.Dispatch(Event{Type: EventFailure, Title: "failed"})
+	if len(failing.titles) != 1 || len(failingEvent.events) != 1 || len(ok.titles) != 1 {
+		t.Errorf("calls = %d, %d, %d, want every notifier called once", len(failing.titles), len(failingEvent.events), len(ok.titles))
+	}
+}
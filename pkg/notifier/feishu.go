@@ -0,0 +1,71 @@
+// File: pkg/notifier/feishu.go
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// FeishuNotifier 实现了飞书自定义机器人通知
+type FeishuNotifier struct {
+	secret     string
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewFeishuNotifier 创建一个新的飞书通知器实例
+func NewFeishuNotifier(cfg config.FeishuConfig) *FeishuNotifier {
+	base := cfg.APIURL
+	if base == "" {
+		base = "https://open.feishu.cn"
+	}
+	return &FeishuNotifier{
+		secret:     cfg.Secret,
+		apiURL:     strings.TrimRight(base, "/") + "/open-apis/bot/v2/hook/" + cfg.Token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// feishuSign 按飞书签名规则计算签名：以 timestamp+"\n"+secret 为密钥对空内容做 HMAC-SHA256
+func feishuSign(secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Notify 以文本消息发送通知
+func (f *FeishuNotifier) Notify(title, message string) error {
+	payload := map[string]interface{}{
+		"msg_type": "text",
+		"content": map[string]string{
+			"text": title + "\n\n" + message,
+		},
+	}
+	if f.secret != "" {
+		timestamp := time.Now().Unix()
+		payload["timestamp"] = strconv.FormatInt(timestamp, 10)
+		payload["sign"] = feishuSign(f.secret, timestamp)
+	}
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := postJSON(f.httpClient, f.apiURL, payload, &result); err != nil {
+		return fmt.Errorf("failed to send feishu notification: %w", err)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("feishu notification failed with code %d: %s", result.Code, result.Msg)
+	}
+
+	log.Println("Feishu notification sent successfully.")
+	return nil
+}
@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+func TestFeishuNotifySignsBody(t *testing.T) {
+	srv, got := newCaptureServer(t, `{"code":0,"msg":"success"}`)
+	f := NewFeishuNotifier(config.FeishuConfig{Token: "hook-id", Secret: "s3", APIURL: srv.URL})
+	before := time.Now().Unix()
+	if err := f.Notify("title", "message"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.path != "/open-apis/bot/v2/hook/hook-id" {
+		t.Errorf("path = %s", got.path)
+	}
+	timestamp, _ := got.body["timestamp"].(string)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || ts < before || ts > time.Now().Unix() {
+		t.Fatalf("timestamp = %v, want current time in seconds", got.body["timestamp"])
+	}
+	// 飞书以 timestamp+"\n"+secret 为密钥，对空内容计算 HMAC-SHA256
+	mac := hmac.New(sha256.New, []byte(timestamp+"\ns3"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); got.body["sign"] != want {
+		t.Errorf("sign = %v, want %q", got.body["sign"], want)
+	}
+	content, _ := got.body["content"].(map[string]interface{})
+	if got.body["msg_type"] != "text" || content["text"] != "title\n\nmessage" {
+		t.Errorf("body = %v", got.body)
+	}
+}
+
+func TestFeishuNotifyReportsErrorCode(t *testing.T) {
+	srv, _ := newCaptureServer(t, `{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`)
+	f := NewFeishuNotifier(config.FeishuConfig{Token: "hook-id", Secret: "wrong", APIURL: srv.URL})
+	wantErrorCode(t, f.Notify("title", "message"), "19021")
+}
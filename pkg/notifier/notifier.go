@@ -1,116 +1,146 @@
-// File: pkg/notifier/notifier.go
-package notifier
-
-import (
-	"bytes"
-	"cfst-client/pkg/config"
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/http"
-	"time"
-
-	"golang.org/x/net/proxy"
-)
-
-// Notifier 定义了通知器的通用接口
-type Notifier interface {
-	Notify(title, message string) error
-}
-
-// TelegramNotifier 实现了 Telegram Bot 通知
-type TelegramNotifier struct {
-	BotToken   string
-	ChatID     string
-	apiURL     string
-	httpClient *http.Client
-}
-
-// NewTelegramNotifier 创建一个新的 Telegram 通知器实例
-func NewTelegramNotifier(cfg config.TelegramConfig) (*TelegramNotifier, error) {
-	// 默认 API 地址
-	apiBaseURL := "https://api.telegram.org"
-
-	// 创建 http client
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	// [核心] 根据配置设置代理
-	if cfg.Proxy.Enabled {
-		switch cfg.Proxy.Type {
-		case "socks5":
-			if cfg.Proxy.Address == "" {
-				return nil, fmt.Errorf("socks5 proxy address is not set")
-			}
-			log.Println("Telegram Notifier: Using SOCKS5 proxy:", cfg.Proxy.Address)
-			dialer, err := proxy.SOCKS5("tcp", cfg.Proxy.Address, nil, proxy.Direct)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create socks5 dialer: %w", err)
-			}
-			transport := &http.Transport{
-				Dial: dialer.Dial,
-			}
-			client.Transport = transport
-		case "reverse_proxy":
-			if cfg.Proxy.ApiURL == "" {
-				return nil, fmt.Errorf("reverse proxy api_url is not set")
-			}
-			log.Println("Telegram Notifier: Using reverse proxy:", cfg.Proxy.ApiURL)
-			// 如果反代链接末尾有斜杠，则去掉
-			apiBaseURL = cfg.Proxy.ApiURL
-			if apiBaseURL[len(apiBaseURL)-1] == '/' {
-				apiBaseURL = apiBaseURL[:len(apiBaseURL)-1]
-			}
-		default:
-			return nil, fmt.Errorf("invalid telegram proxy type: %s", cfg.Proxy.Type)
-		}
-	}
-
-	return &TelegramNotifier{
-		BotToken:   cfg.BotToken,
-		ChatID:     cfg.ChatID,
-		apiURL:     fmt.Sprintf("%s/bot%s/sendMessage", apiBaseURL, cfg.BotToken),
-		httpClient: client,
-	}, nil
-}
-
-// Notify 发送通知
-func (t *TelegramNotifier) Notify(title, message string) error {
-	fullMessage := fmt.Sprintf("<b>%s</b>\n\n%s", title, message)
-
-	body, _ := json.Marshal(map[string]string{
-		"chat_id":    t.ChatID,
-		"text":       fullMessage,
-		"parse_mode": "HTML",
-	})
-
-	req, err := http.NewRequest("POST", t.apiURL, bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := t.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send telegram notification: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram notification failed with status: %s", resp.Status)
-	}
-
-	log.Println("Telegram notification sent successfully.")
-	return nil
-}
-
-// Mock PushPlus Notifier for completeness
-type PushPlusNotifier struct {
-	Token string
-}
-
-func (p *PushPlusNotifier) Notify(title, message string) error {
-	log.Printf("PushPlus notification sent (mock): Title=%s", title)
-	return nil
-}
\ No newline at end of file
+// File: pkg/notifier/notifier.go
+package notifier
+
+import (
+	"bytes"
+	"cfst-client/pkg/config"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Notifier 定义了通知器的通用接口
+type Notifier interface {
+	Notify(title, message string) error
+}
+
+// TelegramNotifier 实现了 Telegram Bot 通知
+type TelegramNotifier struct {
+	BotToken   string
+	ChatID     string
+	apiURL     string // https://api.telegram.org/bot<token>
+	httpClient *http.Client
+}
+
+// NewTelegramNotifier 创建一个新的 Telegram 通知器实例
+func NewTelegramNotifier(cfg config.TelegramConfig) (*TelegramNotifier, error) {
+	client, apiBaseURL, err := newProxyClient("Telegram", cfg.Proxy, "https://api.telegram.org")
+	if err != nil {
+		return nil, err
+	}
+
+	return &TelegramNotifier{
+		BotToken:   cfg.BotToken,
+		ChatID:     cfg.ChatID,
+		apiURL:     fmt.Sprintf("%s/bot%s", apiBaseURL, cfg.BotToken),
+		httpClient: client,
+	}, nil
+}
+
+// newProxyClient 根据代理配置创建 http client，并返回实际使用的 API 地址。
+// 使用反向代理时 API 地址替换为反代链接，name 仅用于日志。
+func newProxyClient(name string, p config.ProxyConfig, apiBaseURL string) (*http.Client, string, error) {
+	// 创建 http client
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	// [核心] 根据配置设置代理
+	if p.Enabled {
+		switch p.Type {
+		case "socks5":
+			if p.Address == "" {
+				return nil, "", fmt.Errorf("socks5 proxy address is not set")
+			}
+			log.Printf("%s Notifier: Using SOCKS5 proxy: %s", name, p.Address)
+			dialer, err := proxy.SOCKS5("tcp", p.Address, nil, proxy.Direct)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to create socks5 dialer: %w", err)
+			}
+			transport := &http.Transport{
+				Dial: dialer.Dial,
+			}
+			client.Transport = transport
+		case "reverse_proxy":
+			if p.ApiURL == "" {
+				return nil, "", fmt.Errorf("reverse proxy api_url is not set")
+			}
+			log.Printf("%s Notifier: Using reverse proxy: %s", name, p.ApiURL)
+			apiBaseURL = p.ApiURL
+		default:
+			return nil, "", fmt.Errorf("invalid %s proxy type: %s", strings.ToLower(name), p.Type)
+		}
+	}
+
+	// 如果链接末尾有斜杠，则去掉
+	return client, strings.TrimRight(apiBaseURL, "/"), nil
+}
+
+// postJSON 以 JSON 格式发送 payload，并在 out 非 nil 时解码响应体。
+// 非 2xx 状态码直接返回错误，业务错误码由调用方根据 out 判断。
+func postJSON(client *http.Client, url string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// Notify 发送通知
+func (t *TelegramNotifier) Notify(title, message string) error {
+	// 标题和内容可能包含错误信息中的 < > &，需要转义，否则 Telegram 会拒绝解析
+	fullMessage := fmt.Sprintf("<b>%s</b>\n\n%s", html.EscapeString(title), html.EscapeString(message))
+	if err := t.sendMessage(t.ChatID, fullMessage); err != nil {
+		return fmt.Errorf("failed to send telegram notification: %w", err)
+	}
+
+	log.Println("Telegram notification sent successfully.")
+	return nil
+}
+
+// sendMessage 向指定会话发送一条 HTML 格式的消息
+func (t *TelegramNotifier) sendMessage(chatID, text string) error {
+	body, _ := json.Marshal(map[string]string{
+		"chat_id":    chatID,
+		"text":       text,
+		"parse_mode": "HTML",
+	})
+
+	req, err := http.NewRequest("POST", t.apiURL+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram request failed with status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+func TestTelegramNotifyEscapesHTML(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/botTOKEN/sendMessage" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	tg, err := NewTelegramNotifier(config.TelegramConfig{
+		BotToken: "TOKEN",
+		ChatID:   "42",
+		Proxy:    config.ProxyConfig{Enabled: true, Type: "reverse_proxy", ApiURL: srv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tg.Notify("update <failed>", "decode release: invalid character '<' & <nil>"); err != nil {
+		t.Fatal(err)
+	}
+	want := "<b>update &lt;failed&gt;</b>\n\ndecode release: invalid character &#39;&lt;&#39; &amp; &lt;nil&gt;"
+	if got["text"] != want {
+		t.Fatalf("text = %q, want %q", got["text"], want)
+	}
+	if got["parse_mode"] != "HTML" {
+		t.Fatalf("parse_mode = %q", got["parse_mode"])
+	}
+}
+
+// captured 记录测试服务器收到的最后一个请求
+type captured struct {
+	path  string
+	query url.Values
+	body  map[string]interface{}
+}
+
+// newCaptureServer 返回一个记录请求并以 response 作为响应体的测试服务器
+func newCaptureServer(t *testing.T, response string) (*httptest.Server, *captured) {
+	t.Helper()
+	c := &captured{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		c.path, c.query = r.URL.Path, r.URL.Query()
+		c.body = nil
+		_ = json.NewDecoder(r.Body).Decode(&c.body)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, c
+}
+
+// wantErrorCode 断言 err 中包含服务端返回的错误码
+func wantErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+	if err == nil || !strings.Contains(err.Error(), "code "+code) {
+		t.Fatalf("Notify error = %v, want code %s", err, code)
+	}
+}
@@ -0,0 +1,97 @@
+// File: pkg/notifier/pushplus.go
+package notifier
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+
+	"cfst-client/pkg/config"
+)
+
+// pushPlusErrors 是 PushPlus 接口返回码的含义
+var pushPlusErrors = map[int]string{
+	302: "not logged in",
+	401: "unauthorized request",
+	403: "request IP is not authorized",
+	500: "PushPlus internal error, try again later",
+	600: "invalid data",
+	805: "no permission",
+	888: "insufficient credits",
+	900: "account is restricted",
+	903: "invalid token",
+	905: "account is not verified with real name",
+	999: "server-side validation error",
+}
+
+// PushPlusNotifier 实现了 PushPlus 推送通知
+type PushPlusNotifier struct {
+	Token      string
+	Template   string
+	Topic      string
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewPushPlusNotifier 创建一个新的 PushPlus 通知器实例
+func NewPushPlusNotifier(cfg config.PushPlusConfig) (*PushPlusNotifier, error) {
+	apiBaseURL := cfg.APIURL
+	if apiBaseURL == "" {
+		apiBaseURL = "https://www.pushplus.plus"
+	}
+	client, apiBaseURL, err := newProxyClient("PushPlus", cfg.Proxy, apiBaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	template := cfg.Template
+	if template == "" {
+		template = "html"
+	}
+	return &PushPlusNotifier{
+		Token:      cfg.Token,
+		Template:   template,
+		Topic:      cfg.Topic,
+		apiURL:     apiBaseURL + "/send",
+		httpClient: client,
+	}, nil
+}
+
+// Notify 发送通知
+func (p *PushPlusNotifier) Notify(title, message string) error {
+	content := message
+	if p.Template == "html" {
+		content = strings.ReplaceAll(html.EscapeString(message), "\n", "<br>")
+	}
+
+	payload := map[string]string{
+		"token":    p.Token,
+		"title":    title,
+		"content":  content,
+		"template": p.Template,
+	}
+	if p.Topic != "" {
+		payload["topic"] = p.Topic
+	}
+
+	// PushPlus 在 HTTP 200 的响应体中通过 code 字段返回业务错误
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := postJSON(p.httpClient, p.apiURL, payload, &result); err != nil {
+		return fmt.Errorf("failed to send pushplus notification: %w", err)
+	}
+	if result.Code != 200 {
+		reason, ok := pushPlusErrors[result.Code]
+		if !ok {
+			reason = "unknown error"
+		}
+		return fmt.Errorf("pushplus notification failed with code %d (%s): %s", result.Code, reason, result.Msg)
+	}
+
+	log.Println("PushPlus notification sent successfully.")
+	return nil
+}
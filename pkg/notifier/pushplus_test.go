@@ -0,0 +1,71 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+func TestPushPlusNotify(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/send" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"code":200,"msg":"ok"}`))
+	}))
+	defer srv.Close()
+
+	p, err := NewPushPlusNotifier(config.PushPlusConfig{Token: "tok", Topic: "grp", APIURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Notify("title", "a < b\nnext"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"token": "tok", "title": "title", "content": "a &lt; b<br>next", "template": "html", "topic": "grp"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestPushPlusNotifyReportsErrorCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":903,"msg":"token invalid"}`))
+	}))
+	defer srv.Close()
+
+	p, err := NewPushPlusNotifier(config.PushPlusConfig{Token: "bad", APIURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Notify("title", "message")
+	if err == nil || !strings.Contains(err.Error(), "903 (invalid token)") {
+		t.Fatalf("Notify error = %v, want code 903 (invalid token)", err)
+	}
+}
+
+func TestPushPlusNotifyReportsHTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	p, err := NewPushPlusNotifier(config.PushPlusConfig{Token: "tok", APIURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Notify("title", "message"); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("Notify error = %v, want 502 status", err)
+	}
+}
@@ -0,0 +1,58 @@
+// File: pkg/notifier/serverchan.go
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// Server酱³ 的 SendKey 形如 sctp{uid}t...，需要使用带 uid 的专属域名
+var serverChan3KeyPattern = regexp.MustCompile(`^sctp(\d+)t`)
+
+// ServerChanNotifier 实现了 Server酱 推送通知
+type ServerChanNotifier struct {
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewServerChanNotifier 创建一个新的 Server酱 通知器实例
+func NewServerChanNotifier(cfg config.ServerChanConfig) *ServerChanNotifier {
+	base := cfg.APIURL
+	if base == "" {
+		base = "https://sctapi.ftqq.com"
+		if m := serverChan3KeyPattern.FindStringSubmatch(cfg.SendKey); m != nil {
+			base = fmt.Sprintf("https://%s.push.ft07.com/send", m[1])
+		}
+	}
+	return &ServerChanNotifier{
+		apiURL:     fmt.Sprintf("%s/%s.send", strings.TrimRight(base, "/"), cfg.SendKey),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Notify 发送通知，desp 字段支持 Markdown
+func (s *ServerChanNotifier) Notify(title, message string) error {
+	payload := map[string]string{
+		"title": title,
+		"desp":  strings.ReplaceAll(message, "\n", "\n\n"),
+	}
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := postJSON(s.httpClient, s.apiURL, payload, &result); err != nil {
+		return fmt.Errorf("failed to send serverchan notification: %w", err)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("serverchan notification failed with code %d: %s", result.Code, result.Message)
+	}
+
+	log.Println("ServerChan notification sent successfully.")
+	return nil
+}
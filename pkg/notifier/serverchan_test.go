@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+func TestServerChanNotify(t *testing.T) {
+	srv, got := newCaptureServer(t, `{"code":0,"message":""}`)
+	s := NewServerChanNotifier(config.ServerChanConfig{SendKey: "SCT1", APIURL: srv.URL})
+	if err := s.Notify("title", "line1\nline2"); err != nil {
+		t.Fatal(err)
+	}
+	if got.path != "/SCT1.send" {
+		t.Errorf("path = %s, want /SCT1.send", got.path)
+	}
+	if got.body["title"] != "title" || got.body["desp"] != "line1\n\nline2" {
+		t.Errorf("body = %v", got.body)
+	}
+}
+
+func TestServerChanNotifyReportsErrorCode(t *testing.T) {
+	srv, _ := newCaptureServer(t, `{"code":40001,"message":"bad pushkey"}`)
+	s := NewServerChanNotifier(config.ServerChanConfig{SendKey: "bad", APIURL: srv.URL})
+	wantErrorCode(t, s.Notify("title", "message"), "40001")
+}
+
+func TestServerChan3URL(t *testing.T) {
+	s := NewServerChanNotifier(config.ServerChanConfig{SendKey: "sctp123tabc"})
+	if want := "https://123.push.ft07.com/send/sctp123tabc.send"; s.apiURL != want {
+		t.Errorf("apiURL = %s, want %s", s.apiURL, want)
+	}
+}
@@ -0,0 +1,209 @@
+// File: pkg/notifier/smtp.go
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// smtpHTMLTemplate 渲染邮件的 HTML 正文，包含结果表格
+var smtpHTMLTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+<h3>{{.Title}}</h3>
+<p style="white-space: pre-line;">{{.Message}}</p>
+{{- if .Results}}
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse: collapse;">
+<tr><th>IP</th><th>Latency (ms)</th><th>Loss</th><th>Speed (MB/s)</th><th>Region</th></tr>
+{{- range .Results}}
+<tr><td>{{.IP}}</td><td>{{.LatencyMs}}</td><td>{{printf "%.2f" .LossPct}}</td><td>{{printf "%.2f" .DLMBps}}</td><td>{{.Region}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Device}}
+<p style="color: #888;">{{.Device}} ({{.Operator}}) · {{.Time.Format "2006-01-02 15:04:05"}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// SMTPNotifier 通过 SMTP 发送邮件通知
+type SMTPNotifier struct {
+	host     string
+	port     int
+	security string
+	username string
+	password string
+	from     string
+	to       []string
+	rootCAs  *x509.CertPool // 校验服务器证书的根证书，nil 时使用系统证书
+}
+
+// NewSMTPNotifier 创建一个新的邮件通知器实例
+func NewSMTPNotifier(cfg config.SMTPConfig) (*SMTPNotifier, error) {
+	if cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("smtp host, from and to must be set")
+	}
+	s := &SMTPNotifier{
+		host:     cfg.Host,
+		port:     cfg.Port,
+		security: cfg.Security,
+		username: cfg.Username,
+		password: cfg.Password,
+		from:     cfg.From,
+		to:       cfg.To,
+	}
+	if s.security == "" {
+		s.security = "starttls"
+	}
+	if s.port == 0 {
+		s.port = 587
+		if s.security == "tls" {
+			s.port = 465
+		}
+	}
+	return s, nil
+}
+
+// Notify 发送只包含标题和内容的邮件
+func (s *SMTPNotifier) Notify(title, message string) error {
+	return s.NotifyEvent(Event{Title: title, Message: message, Time: time.Now()})
+}
+
+// NotifyEvent 发送包含 HTML 结果表格和纯文本备用正文的邮件
+func (s *SMTPNotifier) NotifyEvent(ev Event) error {
+	msg, err := s.buildMessage(ev)
+	if err != nil {
+		return err
+	}
+	if err := s.send(msg); err != nil {
+		return fmt.Errorf("failed to send email notification: %w", err)
+	}
+	log.Printf("Email notification sent successfully to %d recipient(s).", len(s.to))
+	return nil
+}
+
+// buildMessage 生成 multipart/alternative 格式的邮件
+func (s *SMTPNotifier) buildMessage(ev Event) ([]byte, error) {
+	var htmlBody bytes.Buffer
+	if err := smtpHTMLTemplate.Execute(&htmlBody, ev); err != nil {
+		return nil, fmt.Errorf("failed to render email template: %w", err)
+	}
+	text := ev.Message
+	if len(ev.Results) > 0 {
+		text += "\n\n" + FormatResults(ev.Results, len(ev.Results))
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", []byte(text)},
+		{"text/html; charset=UTF-8", htmlBody.Bytes()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		qp.Write(part.content)
+		qp.Close()
+	}
+	mw.Close()
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", ev.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// send 按配置的加密方式连接服务器并投递邮件
+func (s *SMTPNotifier) send(msg []byte) error {
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	tlsConfig := &tls.Config{ServerName: s.host, RootCAs: s.rootCAs}
+
+	var conn net.Conn
+	var err error
+	if s.security == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.security == "starttls" {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls failed: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(envelopeAddress(s.from)); err != nil {
+		return err
+	}
+	for _, rcpt := range s.to {
+		if err := c.Rcpt(envelopeAddress(rcpt)); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// envelopeAddress 从 "Name <user@example.com>" 形式的地址中取出邮箱部分
+func envelopeAddress(s string) string {
+	if addr, err := mail.ParseAddress(s); err == nil {
+		return addr.Address
+	}
+	return s
+}
@@ -0,0 +1,310 @@
+package notifier
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+func TestSMTPBuildMessage(t *testing.T) {
+	s, err := NewSMTPNotifier(config.SMTPConfig{
+		Host: "smtp.example.com",
+		From: "cfst <cfst@example.com>",
+		To:   []string{"a@example.com", "Bob <b@example.com>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := s.buildMessage(Event{
+		Title:    "IPv4 测速完成 <ok>",
+		Message:  "Best: 1.1.1.1",
+		Device:   "nas",
+		Operator: "ct",
+		Time:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Results:  []models.DeviceResult{{IP: "1.1.1.1", LatencyMs: 120, LossPct: 0.25, DLMBps: 12.345, Region: "HKG"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("message does not parse: %v\n%s", err, raw)
+	}
+	if subject := msg.Header.Get("Subject"); !strings.HasPrefix(subject, "=?UTF-8?b?") {
+		t.Errorf("Subject is not B-encoded: %q", subject)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "IPv4 测速完成 <ok>" {
+		t.Errorf("decoded Subject = %q, %v", subject, err)
+	}
+	to, err := msg.Header.AddressList("To")
+	if err != nil || len(to) != 2 || to[0].Address != "a@example.com" || to[1].Address != "b@example.com" || to[1].Name != "Bob" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err != nil || from.Address != "cfst@example.com" {
+		t.Errorf("From = %v, %v", from, err)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(p) // quoted-printable 由 multipart.Reader 解码，换行为 CRLF
+		parts[p.Header.Get("Content-Type")] = string(body)
+	}
+	text := parts["text/plain; charset=UTF-8"]
+	if !strings.HasPrefix(text, "Best: 1.1.1.1\r\n\r\n") || !strings.Contains(text, "1.1.1.1  120ms  12.35MB/s") {
+		t.Errorf("text part = %q", text)
+	}
+	html := parts["text/html; charset=UTF-8"]
+	for _, want := range []string{
+		"<h3>IPv4 测速完成 &lt;ok&gt;</h3>",
+		"<tr><td>1.1.1.1</td><td>120</td><td>0.25</td><td>12.35</td><td>HKG</td></tr>",
+		"nas (ct) · 2026-01-02 03:04:05",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html part does not contain %q:\n%s", want, html)
+		}
+	}
+}
+
+func TestSMTPDefaultPorts(t *testing.T) {
+	tests := []struct {
+		security string
+		port     int
+		want     int
+	}{
+		{"", 0, 587},
+		{"starttls", 0, 587},
+		{"tls", 0, 465},
+		{"none", 0, 587},
+		{"tls", 2465, 2465},
+	}
+	for _, tt := range tests {
+		s, err := NewSMTPNotifier(config.SMTPConfig{Host: "h", From: "f@h", To: []string{"t@h"}, Security: tt.security, Port: tt.port})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.port != tt.want {
+			t.Errorf("security %q port %d: got port %d, want %d", tt.security, tt.port, s.port, tt.want)
+		}
+	}
+}
+
+// fakeSMTP 是一个最小的 SMTP 服务器，记录会话中的关键信息
+type fakeSMTP struct {
+	tlsConfig *tls.Config
+	mu        sync.Mutex
+	tlsAtDial bool
+	startTLS  bool
+	authed    bool
+	from      string
+	rcpts     []string
+	data      string
+}
+
+func (f *fakeSMTP) serve(t *testing.T, ln net.Listener) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, implicit := conn.(*tls.Conn)
+	f.mu.Lock()
+	f.tlsAtDial = implicit
+	f.mu.Unlock()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+	reply("220 fake ESMTP")
+	secure := implicit
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0])
+		f.mu.Lock()
+		switch {
+		case verb == "EHLO":
+			if !secure {
+				reply("250-fake")
+				reply("250-STARTTLS")
+			} else {
+				reply("250-fake")
+			}
+			reply("250 AUTH PLAIN")
+		case verb == "STARTTLS":
+			reply("220 ready")
+			tc := tls.Server(conn, f.tlsConfig)
+			if err := tc.Handshake(); err != nil {
+				f.mu.Unlock()
+				t.Errorf("STARTTLS handshake: %v", err)
+				return
+			}
+			conn, r, secure = tc, bufio.NewReader(tc), true
+			f.startTLS = true
+		case verb == "AUTH":
+			f.authed = true
+			reply("235 ok")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			f.from = strings.Trim(strings.TrimPrefix(cmd, "MAIL FROM:"), "<>")
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			f.rcpts = append(f.rcpts, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<>"))
+			reply("250 ok")
+		case verb == "DATA":
+			reply("354 go ahead")
+			var sb strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				sb.WriteString(l)
+			}
+			f.data = sb.String()
+			reply("250 queued")
+		case verb == "QUIT":
+			reply("221 bye")
+			f.mu.Unlock()
+			return
+		default:
+			reply("502 unsupported")
+		}
+		f.mu.Unlock()
+	}
+}
+
+// newSMTPTestCert 生成 127.0.0.1 的自签名证书
+func newSMTPTestCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestSMTPSecurityModes(t *testing.T) {
+	cert, pool := newSMTPTestCert(t)
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	tests := []struct {
+		security     string
+		username     string
+		wantImplicit bool
+		wantStartTLS bool
+	}{
+		{"tls", "user", true, false},
+		{"starttls", "user", false, true},
+		{"none", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.security, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantImplicit {
+				ln = tls.NewListener(ln, tlsConfig)
+			}
+			defer ln.Close()
+			fake := &fakeSMTP{tlsConfig: tlsConfig}
+			served := make(chan struct{})
+			go func() {
+				defer close(served)
+				fake.serve(t, ln)
+			}()
+
+			port := ln.Addr().(*net.TCPAddr).Port
+			s, err := NewSMTPNotifier(config.SMTPConfig{
+				Host:     "127.0.0.1",
+				Port:     port,
+				Security: tt.security,
+				Username: tt.username,
+				Password: "secret",
+				From:     "cfst <cfst@example.com>",
+				To:       []string{"a@example.com", "Bob <b@example.com>"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.rootCAs = pool
+			if err := s.Notify("title", "body"); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			<-served
+
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if fake.tlsAtDial != tt.wantImplicit || fake.startTLS != tt.wantStartTLS {
+				t.Errorf("implicit TLS = %v, STARTTLS = %v, want %v, %v", fake.tlsAtDial, fake.startTLS, tt.wantImplicit, tt.wantStartTLS)
+			}
+			if fake.authed != (tt.username != "") {
+				t.Errorf("authenticated = %v", fake.authed)
+			}
+			if fake.from != "cfst@example.com" || !reflect.DeepEqual(fake.rcpts, []string{"a@example.com", "b@example.com"}) {
+				t.Errorf("envelope = %s -> %v", fake.from, fake.rcpts)
+			}
+			if !strings.Contains(fake.data, "Subject: title\r\n") {
+				t.Errorf("data does not contain the subject:\n%s", fake.data)
+			}
+		})
+	}
+}
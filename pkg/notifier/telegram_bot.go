@@ -0,0 +1,136 @@
+// File: pkg/notifier/telegram_bot.go
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// telegramPollTimeout 是 getUpdates 长轮询的等待时间，需小于 http client 的超时时间
+const telegramPollTimeout = 25
+
+// CommandHandler 处理机器人收到的命令（不含斜杠，如 run），返回 HTML 格式的回复
+type CommandHandler func(command string, args []string) string
+
+// TelegramBot 通过 getUpdates 长轮询接收命令，只响应配置中的 ChatID
+type TelegramBot struct {
+	*TelegramNotifier
+	handler CommandHandler
+}
+
+// NewTelegramBot 创建一个新的 Telegram 机器人，代理设置与通知器相同
+func NewTelegramBot(cfg config.TelegramConfig, handler CommandHandler) (*TelegramBot, error) {
+	tn, err := NewTelegramNotifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &TelegramBot{TelegramNotifier: tn, handler: handler}, nil
+}
+
+// telegramUpdate 是 getUpdates 返回的单条更新，只保留需要的字段
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Date int64  `json:"date"`
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// Run 持续轮询并处理命令，直到 ctx 被取消。
+// 启动前积压的消息会被忽略，避免重启后重复执行旧命令。
+func (b *TelegramBot) Run(ctx context.Context) {
+	log.Println("Telegram bot: Listening for commands.")
+	startedAt := time.Now().Unix()
+	var offset int64
+	for ctx.Err() == nil {
+		updates, err := b.getUpdates(ctx, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("WARN: Telegram bot: Failed to get updates: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil || u.Message.Date < startedAt {
+				continue
+			}
+			b.handleMessage(strconv.FormatInt(u.Message.Chat.ID, 10), u.Message.Text)
+		}
+	}
+	log.Println("Telegram bot: Stopped.")
+}
+
+// getUpdates 长轮询获取 offset 之后的消息
+func (b *TelegramBot) getUpdates(ctx context.Context, offset int64) ([]telegramUpdate, error) {
+	query := url.Values{
+		"timeout":         {strconv.Itoa(telegramPollTimeout)},
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"allowed_updates": {`["message"]`},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", b.apiURL+"/getUpdates?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool             `json:"ok"`
+		Description string           `json:"description"`
+		Result      []telegramUpdate `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response (status %s): %w", resp.Status, err)
+	}
+	if !result.OK {
+		return nil, fmt.Errorf("telegram api error: %s", result.Description)
+	}
+	return result.Result, nil
+}
+
+// handleMessage 解析命令并回复，其他会话发来的消息会被忽略
+func (b *TelegramBot) handleMessage(chatID, text string) {
+	if chatID != b.ChatID {
+		log.Printf("WARN: Telegram bot: Ignoring message from unauthorized chat %s.", chatID)
+		return
+	}
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return
+	}
+	// 群组中的命令形如 /run@MyBot
+	command := strings.TrimPrefix(fields[0], "/")
+	if i := strings.Index(command, "@"); i >= 0 {
+		command = command[:i]
+	}
+
+	log.Printf("Telegram bot: Received command /%s.", command)
+	reply := b.handler(strings.ToLower(command), fields[1:])
+	if reply == "" {
+		return
+	}
+	if err := b.sendMessage(b.ChatID, reply); err != nil {
+		log.Printf("WARN: Telegram bot: Failed to reply to /%s: %v", command, err)
+	}
+}
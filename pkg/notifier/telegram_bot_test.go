@@ -0,0 +1,131 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// fakeTelegram 是一个只实现 getUpdates 和 sendMessage 的 Bot API，
+// 第一次 getUpdates 返回 updates 生成的消息，之后返回空列表
+type fakeTelegram struct {
+	mu      sync.Mutex
+	updates func() []map[string]interface{}
+	offsets []string
+	replies []map[string]string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/botTOKEN/getUpdates":
+		var result []map[string]interface{}
+		if len(f.offsets) == 0 {
+			result = f.updates()
+		} else {
+			time.Sleep(5 * time.Millisecond) // 模拟长轮询，避免忙等
+		}
+		f.offsets = append(f.offsets, r.URL.Query().Get("offset"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+	case "/botTOKEN/sendMessage":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		f.replies = append(f.replies, body)
+		w.Write([]byte(`{"ok":true}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func message(id int64, chatID int64, date int64, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"update_id": id,
+		"message":   map[string]interface{}{"date": date, "text": text, "chat": map[string]interface{}{"id": chatID}},
+	}
+}
+
+func TestTelegramBotCommands(t *testing.T) {
+	fake := &fakeTelegram{}
+	fake.updates = func() []map[string]interface{} {
+		now := time.Now().Unix()
+		return []map[string]interface{}{
+			message(100, 42, now-3600, "/run"),          // 启动前积压的消息
+			message(101, 99, now, "/run"),               // 未授权的会话
+			message(102, 42, now, "hello"),              // 不是命令
+			message(103, 42, now, "/Run@CfstBot now 1"), // 群组中带机器人名称的命令
+			{"update_id": 104},                          // 非消息更新
+		}
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var commands []string
+	handled := make(chan struct{}, 10)
+	bot, err := NewTelegramBot(config.TelegramConfig{
+		BotToken: "TOKEN",
+		ChatID:   "42",
+		Proxy:    config.ProxyConfig{Enabled: true, Type: "reverse_proxy", ApiURL: srv.URL},
+	}, func(command string, args []string) string {
+		mu.Lock()
+		commands = append(commands, fmt.Sprintf("%s %v", command, args))
+		mu.Unlock()
+		handled <- struct{}{}
+		return "Test <b>started</b>."
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no command was handled")
+	}
+	// 等待机器人用新的 offset 再次轮询，确保所有更新都已处理
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.mu.Lock()
+		polled := len(fake.offsets)
+		fake.mu.Unlock()
+		if polled >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop after cancel")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"run [now 1]"}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("handled commands = %q, want %q", commands, want)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.offsets) < 2 || fake.offsets[0] != "0" || fake.offsets[1] != "105" {
+		t.Errorf("getUpdates offsets = %v, want 0 then 105", fake.offsets)
+	}
+	if len(fake.replies) != 1 || fake.replies[0]["chat_id"] != "42" || fake.replies[0]["text"] != "Test <b>started</b>." {
+		t.Errorf("replies = %v", fake.replies)
+	}
+}
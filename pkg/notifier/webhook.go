@@ -0,0 +1,214 @@
+// File: pkg/notifier/webhook.go
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// 内置模板，字段与 Event 一致
+const (
+	defaultWebhookJSONTemplate = `{"type":{{json .Type}},"title":{{json .Title}},"message":{{json .Message}},` +
+		`"device":{{json .Device}},"operator":{{json .Operator}},"ip_version":{{json .IPVersion}},` +
+		`"time":{{json .Time}},"results":{{json .Results}}}`
+	defaultWebhookFormTemplate = `type={{urlquery .Type}}&title={{urlquery .Title}}&message={{urlquery .Message}}` +
+		`&device={{urlquery .Device}}&operator={{urlquery .Operator}}&ip_version={{urlquery .IPVersion}}`
+)
+
+// webhookFuncs 是 Webhook 模板中可用的额外函数
+var webhookFuncs = template.FuncMap{
+	// json 将任意值编码为 JSON，便于在 JSON 模板中安全地嵌入字符串和结果列表
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// WebhookNotifier 将事件渲染为自定义请求体并发送到指定 URL
+type WebhookNotifier struct {
+	Name            string
+	url             string
+	method          string
+	contentType     string
+	headers         map[string]string
+	secret          []byte
+	signatureHeader string
+	maxRetries      int
+	backoff         time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	tmpl            *template.Template
+	httpClient      *http.Client
+	ctx             context.Context // 取消后放弃尚未进行的后台重试
+	retries         sync.WaitGroup  // 正在后台进行的重试
+}
+
+// NewWebhookNotifier 创建一个新的 Webhook 通知器实例
+func NewWebhookNotifier(cfg config.WebhookConfig) (*WebhookNotifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook url is not set")
+	}
+
+	w := &WebhookNotifier{
+		Name:            cfg.Name,
+		url:             cfg.URL,
+		method:          strings.ToUpper(cfg.Method),
+		headers:         cfg.Headers,
+		secret:          []byte(cfg.Secret),
+		signatureHeader: cfg.SignatureHeader,
+		maxRetries:      3,
+		backoff:         time.Second,
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+		ctx:             context.Background(),
+	}
+	if w.Name == "" {
+		w.Name = cfg.URL
+	}
+	if w.method == "" {
+		w.method = http.MethodPost
+	}
+	if w.signatureHeader == "" {
+		w.signatureHeader = "X-Signature"
+	}
+	if cfg.MaxRetries != nil {
+		w.maxRetries = *cfg.MaxRetries // 0 表示不重试
+	}
+
+	text := cfg.Template
+	switch cfg.Format {
+	case "", "json":
+		w.contentType = "application/json"
+		if text == "" {
+			text = defaultWebhookJSONTemplate
+		}
+	case "form":
+		w.contentType = "application/x-www-form-urlencoded"
+		if text == "" {
+			text = defaultWebhookFormTemplate
+		}
+	default:
+		return nil, fmt.Errorf("invalid webhook format: %s", cfg.Format)
+	}
+
+	tmpl, err := template.New("webhook").Funcs(webhookFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse webhook template: %w", err)
+	}
+	w.tmpl = tmpl
+	return w, nil
+}
+
+// SetContext 设置后台重试使用的 context，ctx 取消后不再重试
+func (w *WebhookNotifier) SetContext(ctx context.Context) {
+	w.ctx = ctx
+}
+
+// Notify 发送只包含标题和内容的通知
+func (w *WebhookNotifier) Notify(title, message string) error {
+	return w.NotifyEvent(Event{Title: title, Message: message, Time: time.Now()})
+}
+
+// NotifyEvent 使用模板渲染事件并发送。第一次发送遇到 5xx 或网络错误时返回 nil，
+// 并在后台按指数退避重试，避免阻塞测试和其他通知器；重试的结果只记录在日志中。
+func (w *WebhookNotifier) NotifyEvent(ev Event) error {
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, ev); err != nil {
+		return fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	body := buf.Bytes()
+	if w.contentType == "application/json" && !json.Valid(body) {
+		return fmt.Errorf("webhook template did not produce valid JSON")
+	}
+
+	var signature string
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	// 第一次发送不受 ctx 影响，保证退出时的 shutdown 通知仍能发出
+	retry, err := w.send(context.Background(), body, signature)
+	if err == nil {
+		log.Printf("Webhook notification sent successfully to %s.", w.Name)
+		return nil
+	}
+	if !retry || w.maxRetries == 0 {
+		return err
+	}
+	w.retries.Add(1)
+	go func() {
+		defer w.retries.Done()
+		w.retry(body, signature, err)
+	}()
+	return nil
+}
+
+// retry 在后台按指数退避重试，ctx 取消时放弃剩余的重试
+func (w *WebhookNotifier) retry(body []byte, signature string, lastErr error) {
+	backoff := w.backoff
+	for attempt := 1; attempt <= w.maxRetries; attempt++ {
+		log.Printf("Webhook %s: retrying in %v (attempt %d/%d): %v", w.Name, backoff, attempt, w.maxRetries, lastErr)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-w.ctx.Done():
+			timer.Stop()
+			log.Printf("WARN: Webhook %s: giving up retries (%v); last error: %v", w.Name, w.ctx.Err(), lastErr)
+			return
+		}
+		backoff *= 2
+
+		retry, err := w.send(w.ctx, body, signature)
+		if err == nil {
+			log.Printf("Webhook notification sent successfully to %s.", w.Name)
+			return
+		}
+		if !retry {
+			log.Printf("WARN: Webhook %s failed: %v", w.Name, err)
+			return
+		}
+		lastErr = err
+	}
+	log.Printf("WARN: Webhook %s failed after %d retries: %v", w.Name, w.maxRetries, lastErr)
+}
+
+// send 发送一次请求，返回的 retry 表示错误是否值得重试
+func (w *WebhookNotifier) send(ctx context.Context, body []byte, signature string) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, w.method, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", w.contentType)
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	if signature != "" {
+		req.Header.Set(w.signatureHeader, signature)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send webhook notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("webhook notification failed with status: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	return resp.StatusCode >= 500, err
+}
@@ -0,0 +1,211 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+// webhookRequest 记录测试服务器收到的 Webhook 请求
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookServer 返回依次以 statuses 响应（用尽后返回 200）的测试服务器及其收到的请求
+func newWebhookServer(t *testing.T, statuses ...int) (*httptest.Server, *[]webhookRequest) {
+	t.Helper()
+	var reqs []webhookRequest
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs = append(reqs, webhookRequest{header: r.Header.Clone(), body: body})
+		if i := int(n.Add(1)) - 1; i < len(statuses) {
+			w.WriteHeader(statuses[i])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func newTestWebhook(t *testing.T, cfg config.WebhookConfig) *WebhookNotifier {
+	t.Helper()
+	w, err := NewWebhookNotifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.backoff = time.Millisecond
+	return w
+}
+
+func testEvent() Event {
+	return Event{
+		Type:      EventSuccess,
+		Title:     "IP4 done",
+		Message:   "a & b",
+		Device:    "nas",
+		Operator:  "ct",
+		IPVersion: "4",
+		Time:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Results:   []models.DeviceResult{{IP: "1.1.1.1", LatencyMs: 10}},
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	srv, reqs := newWebhookServer(t)
+	w := newTestWebhook(t, config.WebhookConfig{URL: srv.URL, Secret: "s3cret", SignatureHeader: "X-Hub-Signature-256"})
+	if err := w.NotifyEvent(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	got := (*reqs)[0]
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(got.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if sig := got.header.Get("X-Hub-Signature-256"); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+	if got.header.Get("X-Signature") != "" {
+		t.Errorf("default signature header set although a custom one is configured")
+	}
+}
+
+func TestWebhookJSONBody(t *testing.T) {
+	srv, reqs := newWebhookServer(t)
+	w := newTestWebhook(t, config.WebhookConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer x"}})
+	if err := w.NotifyEvent(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	got := (*reqs)[0]
+	if ct := got.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if got.header.Get("Authorization") != "Bearer x" {
+		t.Errorf("custom header missing: %v", got.header)
+	}
+	if got.header.Get("X-Signature") != "" {
+		t.Errorf("signature sent without a secret")
+	}
+	var body struct {
+		Type    string                `json:"type"`
+		Title   string                `json:"title"`
+		Message string                `json:"message"`
+		Results []models.DeviceResult `json:"results"`
+	}
+	if err := json.Unmarshal(got.body, &body); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, got.body)
+	}
+	if body.Type != string(EventSuccess) || body.Title != "IP4 done" || body.Message != "a & b" ||
+		len(body.Results) != 1 || body.Results[0].IP != "1.1.1.1" {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestWebhookFormBody(t *testing.T) {
+	srv, reqs := newWebhookServer(t)
+	w := newTestWebhook(t, config.WebhookConfig{URL: srv.URL, Format: "form"})
+	if err := w.NotifyEvent(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	got := (*reqs)[0]
+	if ct := got.header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	form, err := url.ParseQuery(string(got.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Get("title") != "IP4 done" || form.Get("message") != "a & b" || form.Get("device") != "nas" {
+		t.Errorf("form = %v", form)
+	}
+}
+
+func TestWebhookCustomTemplate(t *testing.T) {
+	srv, reqs := newWebhookServer(t)
+	w := newTestWebhook(t, config.WebhookConfig{URL: srv.URL, Template: `{"text":{{json .Title}}}`})
+	if err := w.NotifyEvent(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if got := string((*reqs)[0].body); got != `{"text":"IP4 done"}` {
+		t.Errorf("body = %s", got)
+	}
+
+	w = newTestWebhook(t, config.WebhookConfig{URL: srv.URL, Template: `{"text":{{.Title}}}`})
+	if err := w.NotifyEvent(testEvent()); err == nil || !strings.Contains(err.Error(), "valid JSON") {
+		t.Errorf("invalid JSON template error = %v", err)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	tests := []struct {
+		name       string
+		maxRetries *int
+		statuses   []int
+		wantReqs   int
+		wantErr    bool
+	}{
+		{"retries 5xx until success", nil, []int{500, 502}, 3, false},
+		{"gives up after max retries", intPtr(2), []int{500, 500, 500, 500}, 3, false},
+		{"does not retry 4xx", nil, []int{400}, 1, true},
+		{"max_retries 0 disables retries", intPtr(0), []int{503}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, reqs := newWebhookServer(t, tt.statuses...)
+			w := newTestWebhook(t, config.WebhookConfig{URL: srv.URL, MaxRetries: tt.maxRetries})
+			err := w.NotifyEvent(testEvent())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NotifyEvent error = %v, wantErr %v", err, tt.wantErr)
+			}
+			w.retries.Wait()
+			if len(*reqs) != tt.wantReqs {
+				t.Errorf("server got %d requests, want %d", len(*reqs), tt.wantReqs)
+			}
+		})
+	}
+}
+
+func TestWebhookRetriesDoNotBlock(t *testing.T) {
+	srv, reqs := newWebhookServer(t, 500, 500)
+	w := newTestWebhook(t, config.WebhookConfig{URL: srv.URL})
+	w.backoff = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	w.SetContext(ctx)
+
+	start := time.Now()
+	if err := w.NotifyEvent(testEvent()); err != nil {
+		t.Fatalf("NotifyEvent = %v, want nil while retrying in the background", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("NotifyEvent took %v, want it to return without waiting for the backoff", elapsed)
+	}
+
+	// 取消后后台重试立即结束，不再发送请求
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		w.retries.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background retries did not stop after the context was cancelled")
+	}
+	if len(*reqs) != 1 {
+		t.Errorf("server got %d requests, want only the first attempt", len(*reqs))
+	}
+}
@@ -0,0 +1,54 @@
+// File: pkg/notifier/wecom.go
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// WeComNotifier 实现了企业微信群机器人通知
+type WeComNotifier struct {
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewWeComNotifier 创建一个新的企业微信通知器实例
+func NewWeComNotifier(cfg config.WeComConfig) *WeComNotifier {
+	base := cfg.APIURL
+	if base == "" {
+		base = "https://qyapi.weixin.qq.com"
+	}
+	return &WeComNotifier{
+		apiURL:     strings.TrimRight(base, "/") + "/cgi-bin/webhook/send?key=" + url.QueryEscape(cfg.Key),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Notify 以 Markdown 消息发送通知
+func (w *WeComNotifier) Notify(title, message string) error {
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": fmt.Sprintf("**%s**\n%s", title, message),
+		},
+	}
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := postJSON(w.httpClient, w.apiURL, payload, &result); err != nil {
+		return fmt.Errorf("failed to send wecom notification: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("wecom notification failed with code %d: %s", result.ErrCode, result.ErrMsg)
+	}
+
+	log.Println("WeCom notification sent successfully.")
+	return nil
+}
@@ -0,0 +1,28 @@
+package notifier
+
+import (
+	"testing"
+
+	"cfst-client/pkg/config"
+)
+
+func TestWeComNotify(t *testing.T) {
+	srv, got := newCaptureServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	w := NewWeComNotifier(config.WeComConfig{Key: "k&1", APIURL: srv.URL})
+	if err := w.Notify("title", "message"); err != nil {
+		t.Fatal(err)
+	}
+	if got.path != "/cgi-bin/webhook/send" || got.query.Get("key") != "k&1" {
+		t.Errorf("request = %s?%s", got.path, got.query.Encode())
+	}
+	md, _ := got.body["markdown"].(map[string]interface{})
+	if got.body["msgtype"] != "markdown" || md["content"] != "**title**\nmessage" {
+		t.Errorf("body = %v", got.body)
+	}
+}
+
+func TestWeComNotifyReportsErrorCode(t *testing.T) {
+	srv, _ := newCaptureServer(t, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	w := NewWeComNotifier(config.WeComConfig{Key: "bad", APIURL: srv.URL})
+	wantErrorCode(t, w.Notify("title", "message"), "93000")
+}
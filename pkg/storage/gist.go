@@ -0,0 +1,31 @@
+// File: pkg/storage/gist.go
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"cfst-client/pkg/gist"
+	"cfst-client/pkg/models"
+)
+
+// GistStorage 将结果上传到 GitHub Gist
+type GistStorage struct {
+	name   string
+	client *gist.Client
+	gistID string
+}
+
+// Name 返回用于日志和通知的目标名称
+func (g *GistStorage) Name() string { return g.name }
+
+// Store 将 content 作为 filename 写入 Gist，404 时提示检查 Gist ID 和 Token 权限
+func (g *GistStorage) Store(filename string, content models.GistContent) error {
+	if err := g.client.PushResults(g.gistID, filename, content); err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return fmt.Errorf("%w (please check Gist ID and GITHUB_TOKEN permissions)", err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// File: pkg/storage/local.go
+package storage
+
+import (
+	"os"
+	"path/filepath"
+
+	"cfst-client/pkg/models"
+)
+
+// LocalStorage 将结果写入本地目录
+type LocalStorage struct {
+	name string
+	dir  string
+}
+
+// Name 返回用于日志和通知的目标名称
+func (l *LocalStorage) Name() string { return l.name }
+
+// Store 将 content 原子地写入 dir/filename
+func (l *LocalStorage) Store(filename string, content models.GistContent) error {
+	data, err := marshal(content)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+
+	// 先写入临时文件再重命名，避免读取方看到写了一半的文件
+	tmp, err := os.CreateTemp(l.dir, "."+filename+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(l.dir, filename))
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+func TestLocalStoreWritesFile(t *testing.T) {
+	configDir := t.TempDir()
+	s, err := New(config.StorageConfig{Type: "local", Path: "results"}, &config.Config{}, configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != "local" {
+		t.Errorf("Name() = %q, want local", s.Name())
+	}
+
+	content := models.GistContent{Timestamp: "2026-01-02 03:04:05"}
+	for i := 0; i < 2; i++ { // 第二次写入覆盖同名文件
+		if err := s.Store("cf.json", content); err != nil {
+			t.Fatalf("Store: %v", err)
+		}
+	}
+
+	dir := filepath.Join(configDir, "results")
+	got, err := os.ReadFile(filepath.Join(dir, "cf.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := marshal(content)
+	if string(got) != string(want) {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only cf.json (no leftover temp files)", len(entries))
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+func TestCanonicalURI(t *testing.T) {
+	cases := map[string]string{
+		"":                             "/",
+		"/bucket/results/a.json":       "/bucket/results/a.json",
+		"/bucket/a+b:c=d@e,f.json":     "/bucket/a%2Bb%3Ac%3Dd%40e%2Cf.json",
+		"/bucket/with space/~x_y-z.js": "/bucket/with%20space/~x_y-z.js",
+		"/bucket/中":                    "/bucket/%E4%B8%AD",
+	}
+	for in, want := range cases {
+		if got := canonicalURI(in); got != want {
+			t.Errorf("canonicalURI(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestS3StoreSignsRequest(t *testing.T) {
+	const wantPath = "/bkt/res/a%2Bb%3Ac.json"
+	var gotErr error
+	var gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotErr = verifyS3Signature(r, wantPath, body, "secret", "eu-west-1")
+		if gotErr != nil {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := NewS3Storage("s3", config.StorageConfig{
+		Endpoint:  srv.URL,
+		Region:    "eu-west-1",
+		Bucket:    "bkt",
+		Prefix:    "/res/",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := models.GistContent{Timestamp: "2026-01-02 03:04:05"}
+	if err := s.Store("a+b:c.json", content); err != nil {
+		t.Fatalf("Store: %v (server: %v)", err, gotErr)
+	}
+	want, _ := marshal(content)
+	if gotBody != string(want) {
+		t.Errorf("body = %q, want %q", gotBody, want)
+	}
+}
+
+// verifyS3Signature 按服务端收到的请求重新计算 SigV4 签名并与 Authorization 比较
+func verifyS3Signature(r *http.Request, wantPath string, body []byte, secret, region string) error {
+	if r.Method != http.MethodPut {
+		return fmt.Errorf("method = %s", r.Method)
+	}
+	if raw := strings.SplitN(r.RequestURI, "?", 2)[0]; raw != wantPath {
+		return fmt.Errorf("request path = %s, want %s", raw, wantPath)
+	}
+	payloadHash := sha256Hex(body)
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != payloadHash {
+		return fmt.Errorf("x-amz-content-sha256 = %s, want %s", got, payloadHash)
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return fmt.Errorf("x-amz-date = %q", amzDate)
+	}
+	date := amzDate[:8]
+
+	canonicalRequest := strings.Join([]string{
+		"PUT",
+		wantPath,
+		"",
+		"content-type:" + r.Header.Get("Content-Type") + "\n" +
+			"host:" + r.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		"content-type;host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	want := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=AKID/%s, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		scope, hex.EncodeToString(hmacSHA256(key, stringToSign)))
+	if got := r.Header.Get("Authorization"); got != want {
+		return fmt.Errorf("authorization = %q, want %q", got, want)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// File: pkg/storage/storage.go
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/gist"
+	"cfst-client/pkg/models"
+)
+
+// Storage 定义了测速结果存储目标的通用接口
+type Storage interface {
+	// Name 返回用于日志和通知的目标名称
+	Name() string
+	// Store 以 filename 为文件名保存 content，同名文件会被覆盖
+	Store(filename string, content models.GistContent) error
+}
+
+// Result 记录一次上传到单个存储目标的结果
+type Result struct {
+	Name string
+	Err  error
+}
+
+// New 根据配置创建存储目标，相对路径基于 configDir
+func New(sc config.StorageConfig, cfg *config.Config, configDir string) (Storage, error) {
+	name := sc.Name
+	if name == "" {
+		name = sc.Type
+	}
+	switch sc.Type {
+	case "gist":
+		if cfg.Gist.GistID == "" {
+			return nil, fmt.Errorf("gist_id is not set")
+		}
+		return &GistStorage{
+			name:   name,
+			client: gist.NewClient(cfg.Gist.Token, cfg.ProxyPrefix),
+			gistID: cfg.Gist.GistID,
+		}, nil
+	case "local":
+		if sc.Path == "" {
+			return nil, fmt.Errorf("local storage path is not set")
+		}
+		dir := sc.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(configDir, dir)
+		}
+		return &LocalStorage{name: name, dir: dir}, nil
+	case "s3":
+		return NewS3Storage(name, sc)
+	case "webdav":
+		return NewWebDAVStorage(name, sc)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %q", sc.Type)
+	}
+}
+
+// StoreAll 将同一份内容依次上传到所有目标，每个目标独立返回结果
+func StoreAll(sinks []Storage, filename string, content models.GistContent) []Result {
+	results := make([]Result, 0, len(sinks))
+	for _, s := range sinks {
+		results = append(results, Result{Name: s.Name(), Err: s.Store(filename, content)})
+	}
+	return results
+}
+
+// marshal 将内容序列化为与 Gist 一致的缩进 JSON
+func marshal(content models.GistContent) ([]byte, error) {
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal content: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,94 @@
+// File: pkg/storage/webdav.go
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+// WebDAVStorage 将结果通过 HTTP PUT 上传到 WebDAV 目录
+type WebDAVStorage struct {
+	name       string
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// NewWebDAVStorage 创建一个新的 WebDAV 存储目标
+func NewWebDAVStorage(name string, sc config.StorageConfig) (*WebDAVStorage, error) {
+	if sc.URL == "" {
+		return nil, fmt.Errorf("webdav url is not set")
+	}
+	if _, err := url.Parse(sc.URL); err != nil {
+		return nil, fmt.Errorf("invalid webdav url: %w", err)
+	}
+	return &WebDAVStorage{
+		name:     name,
+		baseURL:  strings.TrimRight(sc.URL, "/"),
+		username: sc.Username,
+		password: sc.Password,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}, nil
+}
+
+// Name 返回用于日志和通知的目标名称
+func (w *WebDAVStorage) Name() string { return w.name }
+
+// Store 将 content PUT 到 WebDAV 目录下的 filename，目录不存在时先创建
+func (w *WebDAVStorage) Store(filename string, content models.GistContent) error {
+	data, err := marshal(content)
+	if err != nil {
+		return err
+	}
+	target := w.baseURL + "/" + url.PathEscape(filename)
+
+	status, err := w.do(http.MethodPut, target, data)
+	if err != nil {
+		return err
+	}
+	// 409 表示父目录不存在，创建目录后重试一次
+	if status == http.StatusConflict {
+		if status, err := w.do("MKCOL", w.baseURL+"/", nil); err != nil {
+			return err
+		} else if status >= 300 && status != http.StatusMethodNotAllowed {
+			return fmt.Errorf("webdav mkcol failed with status: %d", status)
+		}
+		if status, err = w.do(http.MethodPut, target, data); err != nil {
+			return err
+		}
+	}
+	if status >= 300 {
+		return fmt.Errorf("webdav put failed with status: %d", status)
+	}
+	return nil
+}
+
+// do 发送一次 WebDAV 请求并返回响应状态码
+func (w *WebDAVStorage) do(method, target string, body []byte) (int, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if w.username != "" || w.password != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webdav %s failed: %w", strings.ToLower(method), err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+func TestWebDAVStoreCreatesMissingCollection(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	collection := false
+	files := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.EscapedPath())
+		if user, pass, ok := r.BasicAuth(); !ok || user != "u" || pass != "p" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "MKCOL":
+			collection = true
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			if !collection {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			files[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	w, err := NewWebDAVStorage("dav", config.StorageConfig{URL: srv.URL + "/dav/", Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := models.GistContent{Timestamp: "2026-01-02 03:04:05"}
+	if err := w.Store("cf result.json", content); err != nil {
+		t.Fatalf("Store: %v (calls %v)", err, calls)
+	}
+
+	want := []string{"PUT /dav/cf%20result.json", "MKCOL /dav/", "PUT /dav/cf%20result.json"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %s, want %s", i, calls[i], want[i])
+		}
+	}
+	data, _ := marshal(content)
+	if files["/dav/cf result.json"] != string(data) {
+		t.Errorf("stored files = %v", files)
+	}
+}
+
+func TestWebDAVStoreReportsFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebDAVStorage("dav", config.StorageConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Store("a.json", models.GistContent{}); err == nil {
+		t.Fatal("Store succeeded on 403")
+	}
+}
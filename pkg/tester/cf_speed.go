@@ -1,14 +1,17 @@
 package tester
 
 import (
-	"encoding/csv"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
-	"strconv"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"cfst-client/pkg/models"
 )
@@ -20,8 +23,18 @@ type CFSpeedTester struct {
 	outputFile   string
 	deviceName   string
 	lineOperator string
+	// [新增] 进度回调与原始输出日志
+	progress  ProgressFunc
+	logDir    string
+	logPrefix string
 }
 
+// maxRunLogs 是每个前缀保留的原始输出日志数量
+const maxRunLogs = 20
+
+// killWaitDelay 是进程组被终止后等待输出管道关闭的最长时间
+const killWaitDelay = 5 * time.Second
+
 // NewCFSpeedTester creates a new instance of CFSpeedTester.
 func NewCFSpeedTester(bin, outputFile, deviceName, lineOperator string, args []string) *CFSpeedTester {
 	return &CFSpeedTester{
@@ -33,21 +46,59 @@ func NewCFSpeedTester(bin, outputFile, deviceName, lineOperator string, args []s
 	}
 }
 
+// SetProgress registers a callback that receives progress events parsed from the output.
+func (c *CFSpeedTester) SetProgress(fn ProgressFunc) {
+	c.progress = fn
+}
+
+// SetRunLog saves the raw output of each run to dir/<prefix>-<time>.log,
+// keeping only the newest maxRunLogs files.
+func (c *CFSpeedTester) SetRunLog(dir, prefix string) {
+	c.logDir = dir
+	c.logPrefix = prefix
+}
+
 // Run executes the CloudflareSpeedTest command and parses the results.
-func (c *CFSpeedTester) Run() ([]models.DeviceResult, error) {
+func (c *CFSpeedTester) Run(ctx context.Context) ([]models.DeviceResult, error) {
 	_ = os.Remove(c.outputFile)
 
 	cmdArgs := append(c.args, "-o", c.outputFile)
 	fullCommand := fmt.Sprintf("%s %s", c.bin, strings.Join(cmdArgs, " "))
 	log.Printf("Executing command: %s", fullCommand)
 
-	cmd := exec.Command(c.bin, cmdArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// [修改] 超时或取消时终止整个进程组
+	cmd := exec.CommandContext(ctx, c.bin, cmdArgs...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = killWaitDelay
 
+	// [修改] 输出同时写入终端、本次运行的日志文件和进度解析器
+	writers := []io.Writer{os.Stdout}
+	if logFile := c.openRunLog(); logFile != nil {
+		defer logFile.Close()
+		fmt.Fprintf(logFile, "# %s\n", fullCommand)
+		writers = append(writers, logFile)
+	}
+	var parser *progressParser
+	if c.progress != nil {
+		parser = newProgressParser(c.progress)
+		writers = append(writers, parser)
+	}
+	out := io.MultiWriter(writers...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	start := time.Now()
 	err := cmd.Run()
+	if parser != nil {
+		parser.Flush()
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// 被终止不代表程序本身无法执行，不包装 ErrExec
+		return nil, fmt.Errorf("CloudflareSpeedTest was stopped after %v: %w", time.Since(start).Round(time.Second), ctxErr)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("command execution failed: %w", err)
+		return nil, classifyExecError(err, cmd.ProcessState)
 	}
 	log.Println("CloudflareSpeedTest finished successfully.")
 
@@ -57,49 +108,55 @@ func (c *CFSpeedTester) Run() ([]models.DeviceResult, error) {
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
-	_, err = reader.Read()
-	if err == io.EOF {
-		return nil, fmt.Errorf("result file is empty or missing header: %s", c.outputFile)
-	}
+	// [修改] 按表头解析结果，兼容不同版本的列顺序和语言
+	results, err := parseResultCSV(file, c.deviceName, c.lineOperator, time.Now())
 	if err != nil {
-		return nil, fmt.Errorf("failed to read csv header: %w", err)
+		return nil, fmt.Errorf("%s: %w", c.outputFile, err)
 	}
+	return results, nil
+}
 
-	var results []models.DeviceResult
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("error reading csv record: %w", err)
-		}
-		// [修改] 现在要求至少有 7 列数据
-		if len(record) < 7 {
-			continue
-		}
-
-		ip := record[0]
-		loss, _ := strconv.ParseFloat(record[3], 64)
-		latency, _ := strconv.ParseFloat(record[4], 64)
-		speed, _ := strconv.ParseFloat(record[5], 64)
-		region := record[6] // [新增] 获取地区码
-
-		results = append(results, models.DeviceResult{
-			Device:    c.deviceName,
-			Operator:  c.lineOperator,
-			IP:        ip,
-			LatencyMs: int(latency),
-			LossPct:   loss,
-			DLMBps:    speed,
-			Region:    region, // [新增] 填充地区码字段
-		})
+// classifyExecError 只有程序无法启动或被信号终止时才包装 ErrExec，与安装时的冒烟测试一致；
+// 普通的非零退出码（例如测速过程中网络异常）说明程序本身可以执行
+func classifyExecError(err error, state *os.ProcessState) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("%w: %v", ErrExec, err)
+	}
+	if state != nil && !state.Exited() {
+		return fmt.Errorf("%w: terminated abnormally: %s", ErrExec, state)
 	}
+	return fmt.Errorf("CloudflareSpeedTest failed: %w", err)
+}
 
-	if len(results) == 0 {
-		return nil, fmt.Errorf("no valid results parsed from csv file")
+// openRunLog 创建本次运行的原始输出日志并清理旧日志，失败时只记录警告
+func (c *CFSpeedTester) openRunLog() *os.File {
+	if c.logDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.logDir, 0755); err != nil {
+		log.Printf("WARN: Failed to create log directory %s: %v", c.logDir, err)
+		return nil
+	}
+	c.pruneRunLogs(maxRunLogs - 1)
+	path := filepath.Join(c.logDir, fmt.Sprintf("%s-%s.log", c.logPrefix, time.Now().Format("20060102-150405")))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("WARN: Failed to create run log %s: %v", path, err)
+		return nil
 	}
+	log.Printf("Saving CloudflareSpeedTest output to %s", path)
+	return f
+}
 
-	return results, nil
-}
\ No newline at end of file
+// pruneRunLogs 只保留最新的 keep 个日志文件（文件名包含时间，按名称排序即按时间排序）
+func (c *CFSpeedTester) pruneRunLogs(keep int) {
+	matches, err := filepath.Glob(filepath.Join(c.logDir, c.logPrefix+"-*.log"))
+	if err != nil || len(matches) <= keep {
+		return
+	}
+	sort.Strings(matches)
+	for _, m := range matches[:len(matches)-keep] {
+		_ = os.Remove(m)
+	}
+}
@@ -0,0 +1,137 @@
+//go:build !windows
+
+package tester
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeScript 在临时目录中写入一个可执行的 shell 脚本作为假的 CloudflareSpeedTest
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cfst")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCFSpeedTesterRunErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		bin      string
+		wantExec bool
+	}{
+		{"exit status", writeScript(t, "exit 3"), false},
+		{"killed by signal", writeScript(t, "kill -KILL $$"), true},
+		{"not found", filepath.Join(t.TempDir(), "missing"), true},
+		{"not executable", func() string {
+			p := filepath.Join(t.TempDir(), "cfst")
+			if err := os.WriteFile(p, []byte("#!/bin/sh\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "result.csv")
+			_, err := NewCFSpeedTester(tt.bin, out, "dev", "op", nil).Run(context.Background())
+			if err == nil {
+				t.Fatal("Run succeeded, want error")
+			}
+			if got := errors.Is(err, ErrExec); got != tt.wantExec {
+				t.Errorf("errors.Is(%v, ErrExec) = %v, want %v", err, got, tt.wantExec)
+			}
+		})
+	}
+}
+
+// processGone 判断进程是否已退出；已退出但尚未被回收的僵尸进程也视为退出
+func processGone(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return true
+	}
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	// 格式为 "pid (comm) state ..."，comm 中可能包含空格
+	fields := strings.Fields(string(data[bytes.LastIndexByte(data, ')')+1:]))
+	return len(fields) > 0 && fields[0] == "Z"
+}
+
+func TestCFSpeedTesterRunKillsProcessGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 500*time.Millisecond)
+		}, context.DeadlineExceeded},
+		{"cancel", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(500*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pidFile := filepath.Join(t.TempDir(), "pids")
+			// 脚本启动一个休眠的子进程（即 cfst 的孙进程），记录两者的 PID 后等待
+			bin := writeScript(t, fmt.Sprintf("sleep 60 &\necho $$ $! > %s\nwait", pidFile))
+			out := filepath.Join(t.TempDir(), "result.csv")
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			start := time.Now()
+			_, err := NewCFSpeedTester(bin, out, "dev", "op", nil).Run(ctx)
+			if elapsed := time.Since(start); elapsed > killWaitDelay {
+				t.Errorf("Run returned after %v, want shortly after the context ended", elapsed)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Run error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrExec) {
+				t.Errorf("Run error = %v, must not wrap ErrExec", err)
+			}
+
+			data, err := os.ReadFile(pidFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pids []int
+			for _, f := range strings.Fields(string(data)) {
+				pid, err := strconv.Atoi(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pids = append(pids, pid)
+			}
+			if len(pids) != 2 {
+				t.Fatalf("pid file = %q, want script and child PIDs", data)
+			}
+			for _, pid := range pids {
+				deadline := time.Now().Add(2 * time.Second)
+				for !processGone(pid) && time.Now().Before(deadline) {
+					time.Sleep(20 * time.Millisecond)
+				}
+				if !processGone(pid) {
+					_ = syscall.Kill(pid, syscall.SIGKILL)
+					t.Errorf("process %d is still running after Run returned", pid)
+				}
+			}
+		})
+	}
+}
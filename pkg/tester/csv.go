@@ -0,0 +1,227 @@
+package tester
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfst-client/pkg/models"
+)
+
+// 结果文件中可识别的列
+const (
+	colIP       = "ip"
+	colSent     = "sent"
+	colReceived = "received"
+	colLoss     = "loss"
+	colLatency  = "latency"
+	colSpeed    = "speed"
+	colRegion   = "region"
+)
+
+// csvRequiredColumns 是解析结果必须存在的列，其余列可选（如旧版本没有地区码）
+var csvRequiredColumns = []string{colIP, colLoss, colLatency, colSpeed}
+
+// csvHeaderAliases 把已知 CloudflareSpeedTest 版本的表头映射到列名。
+// 表头先经过 normalizeHeader 处理，因此不区分大小写、空格和单位。
+var csvHeaderAliases = map[string]string{
+	// 中文表头（XIU2/CloudflareSpeedTest v2.x）
+	"ip地址": colIP,
+	"已发送":  colSent,
+	"已接收":  colReceived,
+	"丢包率":  colLoss,
+	"平均延迟": colLatency,
+	"下载速度": colSpeed,
+	"地区码":  colRegion,
+	// 英文表头（英文版及部分衍生版本）
+	"ip":             colIP,
+	"ipaddress":      colIP,
+	"sent":           colSent,
+	"received":       colReceived,
+	"recv":           colReceived,
+	"loss":           colLoss,
+	"lossrate":       colLoss,
+	"packetloss":     colLoss,
+	"latency":        colLatency,
+	"avglatency":     colLatency,
+	"averagelatency": colLatency,
+	"averagedelay":   colLatency,
+	"delay":          colLatency,
+	"downloadspeed":  colSpeed,
+	"speed":          colSpeed,
+	"region":         colRegion,
+	"colo":           colRegion,
+	"regioncode":     colRegion,
+}
+
+// normalizeHeader 去掉 BOM、空白和括号中的单位，如 "下载速度 (MB/s)" -> "下载速度"
+func normalizeHeader(h string) string {
+	h = strings.TrimPrefix(h, "\uFEFF")
+	if i := strings.IndexAny(h, "(（"); i >= 0 {
+		h = h[:i]
+	}
+	h = strings.ToLower(h)
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '_', '-':
+			return -1
+		}
+		return r
+	}, h)
+}
+
+// csvLayout 记录各列在结果文件中的位置
+type csvLayout map[string]int
+
+// parseCSVHeader 按表头识别列位置，缺少必需列时返回错误
+func parseCSVHeader(header []string) (csvLayout, error) {
+	layout := make(csvLayout)
+	for idx, h := range header {
+		if col, ok := csvHeaderAliases[normalizeHeader(h)]; ok {
+			if _, dup := layout[col]; !dup {
+				layout[col] = idx
+			}
+		}
+	}
+	var missing []string
+	for _, col := range csvRequiredColumns {
+		if _, ok := layout[col]; !ok {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unrecognized result file layout %q: missing columns %s", strings.Join(header, ","), strings.Join(missing, ", "))
+	}
+	return layout, nil
+}
+
+// get 返回指定列的值，列不存在或本行较短时返回 false
+func (l csvLayout) get(record []string, col string) (string, bool) {
+	idx, ok := l[col]
+	if !ok || idx >= len(record) {
+		return "", false
+	}
+	return strings.TrimSpace(record[idx]), true
+}
+
+// float 解析数值列，允许丢包率带 % 后缀
+func (l csvLayout) float(record []string, col string) (float64, error) {
+	v, ok := l.get(record, col)
+	if !ok {
+		return 0, fmt.Errorf("missing %s column", col)
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", col, v)
+	}
+	return f, nil
+}
+
+// optionalInt 解析可选的整数列，列不存在或为空时返回 0
+func (l csvLayout) optionalInt(record []string, col string) (int, error) {
+	v, ok := l.get(record, col)
+	if !ok || v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", col, v)
+	}
+	return n, nil
+}
+
+// parseResultCSV 解析 CloudflareSpeedTest 的结果文件。
+// 无法解析的行会被记录并跳过；表头无法识别或没有任何有效行时返回错误。
+func parseResultCSV(r io.Reader, deviceName, lineOperator string, measuredAt time.Time) ([]models.DeviceResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // 列数由表头决定，逐行检查
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("result file is empty or missing header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	layout, err := parseCSVHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.DeviceResult
+	var rowErrs []error
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv record: %w", err)
+		}
+		res, err := parseResultRecord(layout, record)
+		if err != nil {
+			err = fmt.Errorf("row %d: %w", row, err)
+			log.Printf("WARN: Skipping result %v", err)
+			rowErrs = append(rowErrs, err)
+			continue
+		}
+		res.Device = deviceName
+		res.Operator = lineOperator
+		res.MeasuredAt = measuredAt.Format(time.RFC3339)
+		results = append(results, res)
+	}
+
+	if len(results) == 0 {
+		if len(rowErrs) > 0 {
+			return nil, fmt.Errorf("no valid results parsed from csv file: %w", errors.Join(rowErrs...))
+		}
+		return nil, fmt.Errorf("no valid results parsed from csv file")
+	}
+	return results, nil
+}
+
+// parseResultRecord 解析单行结果
+func parseResultRecord(layout csvLayout, record []string) (models.DeviceResult, error) {
+	var res models.DeviceResult
+	ip, ok := layout.get(record, colIP)
+	if !ok || ip == "" {
+		return res, fmt.Errorf("missing ip column")
+	}
+	loss, err := layout.float(record, colLoss)
+	if err != nil {
+		return res, err
+	}
+	latency, err := layout.float(record, colLatency)
+	if err != nil {
+		return res, err
+	}
+	speed, err := layout.float(record, colSpeed)
+	if err != nil {
+		return res, err
+	}
+	region, _ := layout.get(record, colRegion)
+	// [新增] 旧版本没有发送/接收列时保持为 0
+	sent, err := layout.optionalInt(record, colSent)
+	if err != nil {
+		return res, err
+	}
+	received, err := layout.optionalInt(record, colReceived)
+	if err != nil {
+		return res, err
+	}
+
+	res.IP = ip
+	res.LatencyMs = int(latency)
+	res.LossPct = loss
+	res.DLMBps = speed
+	res.Region = region
+	res.Sent = sent
+	res.Received = received
+	res.AvgLatencyMs = latency
+	return res, nil
+}
@@ -0,0 +1,126 @@
+package tester
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/models"
+)
+
+func TestParseResultCSV(t *testing.T) {
+	measuredAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	full := func(ip string, sent, received int, loss, latency, speed float64, region string) models.DeviceResult {
+		return models.DeviceResult{
+			Device: "nas", Operator: "ct", IP: ip, Sent: sent, Received: received,
+			LossPct: loss, LatencyMs: int(latency), AvgLatencyMs: latency, DLMBps: speed,
+			Region: region, MeasuredAt: measuredAt.Format(time.RFC3339),
+		}
+	}
+
+	tests := []struct {
+		name string
+		csv  string
+		want []models.DeviceResult
+	}{
+		{
+			name: "chinese header",
+			csv: "IP 地址,已发送,已接收,丢包率,平均延迟,下载速度 (MB/s),地区码\n" +
+				"104.16.1.1,4,4,0.00,120.52,12.34,HKG\n" +
+				"104.16.1.2,4,3,0.25,130.00,8.50,LAX\n",
+			want: []models.DeviceResult{
+				full("104.16.1.1", 4, 4, 0, 120.52, 12.34, "HKG"),
+				full("104.16.1.2", 4, 3, 0.25, 130, 8.5, "LAX"),
+			},
+		},
+		{
+			name: "english header",
+			csv: "IP Address,Sent,Received,Packet Loss,Average Delay,Download Speed (MB/s),Colo\n" +
+				"104.16.1.1,4,4,0.00,120.52,12.34,HKG\n",
+			want: []models.DeviceResult{full("104.16.1.1", 4, 4, 0, 120.52, 12.34, "HKG")},
+		},
+		{
+			name: "old layout without region",
+			csv: "IP 地址,已发送,已接收,丢包率,平均延迟,下载速度 (MB/s)\n" +
+				"104.16.1.1,4,4,0.00,120.52,12.34\n",
+			want: []models.DeviceResult{full("104.16.1.1", 4, 4, 0, 120.52, 12.34, "")},
+		},
+		{
+			name: "utf-8 bom",
+			csv: "\uFEFFIP 地址,已发送,已接收,丢包率,平均延迟,下载速度 (MB/s),地区码\n" +
+				"104.16.1.1,4,4,0.00,120.52,12.34,HKG\n",
+			want: []models.DeviceResult{full("104.16.1.1", 4, 4, 0, 120.52, 12.34, "HKG")},
+		},
+		{
+			name: "units and full-width brackets",
+			csv: "IP 地址,丢包率（%）,平均延迟 (ms),下载速度（MB/s）\n" +
+				"104.16.1.1,0.5%,99.9,3.5\n",
+			want: []models.DeviceResult{full("104.16.1.1", 0, 0, 0.5, 99.9, 3.5, "")},
+		},
+		{
+			name: "reordered columns",
+			csv: "地区码,下载速度 (MB/s),平均延迟,IP 地址,丢包率,已接收,已发送\n" +
+				"HKG,12.34,120.52,104.16.1.1,0.00,4,4\n",
+			want: []models.DeviceResult{full("104.16.1.1", 4, 4, 0, 120.52, 12.34, "HKG")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseResultCSV(strings.NewReader(tt.csv), "nas", "ct", measuredAt)
+			if err != nil {
+				t.Fatalf("parseResultCSV: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseResultCSV =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseResultCSVSkipsMalformedRows(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	data := "IP 地址,已发送,已接收,丢包率,平均延迟,下载速度 (MB/s),地区码\n" +
+		"104.16.1.1,4,4,0.00,120.52,12.34,HKG\n" +
+		"104.16.1.2,4,4,0.00,slow,12.34,HKG\n" +
+		"104.16.1.3,4\n" +
+		"104.16.1.4,4,4,0.00,90.00,20.00,SJC\n"
+	got, err := parseResultCSV(strings.NewReader(data), "nas", "ct", time.Now())
+	if err != nil {
+		t.Fatalf("parseResultCSV: %v", err)
+	}
+	if len(got) != 2 || got[0].IP != "104.16.1.1" || got[1].IP != "104.16.1.4" {
+		t.Fatalf("parseResultCSV kept %+v, want the two valid rows", got)
+	}
+	for _, want := range []string{"row 3: invalid latency", "row 4: missing loss column"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log %q does not mention %q", logs.String(), want)
+		}
+	}
+}
+
+func TestParseResultCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		wantErr string
+	}{
+		{"unrecognised header", "Host,RTT,Throughput\n1.1.1.1,10,5\n", "unrecognized result file layout"},
+		{"empty file", "", "missing header"},
+		{"only malformed rows", "IP 地址,丢包率,平均延迟,下载速度\n1.1.1.1,x,1,1\n", "no valid results"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseResultCSV(strings.NewReader(tt.csv), "nas", "ct", time.Now())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
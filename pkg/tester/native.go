@@ -0,0 +1,380 @@
+package tester
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cfst-client/pkg/config"
+	"cfst-client/pkg/models"
+)
+
+const (
+	defaultNativeURL           = "https://cf.xiu2.xyz/url"
+	defaultNativePort          = 443
+	defaultNativePingTimes     = 4
+	defaultNativeConcurrency   = 200
+	defaultNativeTimeoutMs     = 1000
+	defaultNativeMaxLatencyMs  = 9999
+	defaultNativeDownloadCount = 10
+	defaultNativeDownloadTime  = 10
+	defaultNativeIPv6Samples   = 64
+)
+
+// NativeTester is a pure-Go speed-test engine. It probes TCP connect latency
+// for a sample of IPs from the IP file, then measures the download speed of
+// the best candidates against a configurable URL.
+type NativeTester struct {
+	opts         config.NativeConfig
+	ipFile       string
+	deviceName   string
+	lineOperator string
+	rootCAs      *x509.CertPool // 为 nil 时使用系统证书，仅测试中设置
+}
+
+// NewNativeTester creates a new instance of NativeTester, filling unset
+// options with defaults similar to CloudflareSpeedTest.
+func NewNativeTester(opts config.NativeConfig, ipFile, deviceName, lineOperator string) *NativeTester {
+	if opts.URL == "" {
+		opts.URL = defaultNativeURL
+	}
+	if opts.Port <= 0 {
+		opts.Port = defaultNativePort
+	}
+	if opts.PingTimes <= 0 {
+		opts.PingTimes = defaultNativePingTimes
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultNativeConcurrency
+	}
+	if opts.TimeoutMs <= 0 {
+		opts.TimeoutMs = defaultNativeTimeoutMs
+	}
+	if opts.MaxLatencyMs <= 0 {
+		opts.MaxLatencyMs = defaultNativeMaxLatencyMs
+	}
+	if opts.DownloadCount <= 0 {
+		opts.DownloadCount = defaultNativeDownloadCount
+	}
+	if opts.DownloadTime <= 0 {
+		opts.DownloadTime = defaultNativeDownloadTime
+	}
+	if opts.IPv6Samples <= 0 {
+		opts.IPv6Samples = defaultNativeIPv6Samples
+	}
+	return &NativeTester{
+		opts:         opts,
+		ipFile:       ipFile,
+		deviceName:   deviceName,
+		lineOperator: lineOperator,
+	}
+}
+
+// pingResult holds the latency probe outcome for a single IP.
+type pingResult struct {
+	ip       netip.Addr
+	sent     int
+	received int
+	latency  time.Duration
+}
+
+func (p pingResult) loss() float64 {
+	return float64(p.sent-p.received) / float64(p.sent)
+}
+
+// Run executes the latency and download phases and returns the results.
+func (n *NativeTester) Run(ctx context.Context) ([]models.DeviceResult, error) {
+	ips, err := n.loadIPs()
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no IPs to test in %s", n.ipFile)
+	}
+	log.Printf("Native engine: probing latency of %d IPs (port %d, %d pings each)...", len(ips), n.opts.Port, n.opts.PingTimes)
+
+	pings := n.probeAll(ctx, ips)
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("native engine was stopped during the latency test: %w", err)
+	}
+	if len(pings) == 0 {
+		return nil, fmt.Errorf("no IP responded to the latency test")
+	}
+	sort.Slice(pings, func(i, j int) bool {
+		if pings[i].loss() != pings[j].loss() {
+			return pings[i].loss() < pings[j].loss()
+		}
+		return pings[i].latency < pings[j].latency
+	})
+	log.Printf("Native engine: %d IPs passed the latency test.", len(pings))
+
+	if len(pings) > n.opts.DownloadCount {
+		pings = pings[:n.opts.DownloadCount]
+	}
+
+	results := make([]models.DeviceResult, 0, len(pings))
+	for idx, p := range pings {
+		speed, colo, err := n.download(ctx, p.ip)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("native engine was stopped during the download test: %w", ctx.Err())
+		}
+		if err != nil {
+			log.Printf("Native engine: download test %d/%d for %s failed: %v", idx+1, len(pings), p.ip, err)
+		} else {
+			log.Printf("Native engine: download test %d/%d for %s: %.2f MB/s (%s)", idx+1, len(pings), p.ip, speed, colo)
+		}
+		results = append(results, models.DeviceResult{
+			Device:    n.deviceName,
+			Operator:  n.lineOperator,
+			IP:        p.ip.String(),
+			LatencyMs: int(p.latency / time.Millisecond),
+			LossPct:   p.loss(),
+			DLMBps:    speed,
+			Region:    colo,
+			// [新增] 扩展字段
+			Sent:         p.sent,
+			Received:     p.received,
+			AvgLatencyMs: float64(p.latency) / float64(time.Millisecond),
+			MeasuredAt:   time.Now().Format(time.RFC3339),
+		})
+	}
+
+	return results, nil
+}
+
+// loadIPs reads the IP file and expands every range into test candidates:
+// one random address per /24 for IPv4 and IPv6Samples random addresses per
+// IPv6 prefix.
+func (n *NativeTester) loadIPs() ([]netip.Addr, error) {
+	f, err := os.Open(n.ipFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ip file '%s': %w", n.ipFile, err)
+	}
+	defer f.Close()
+
+	var ips []netip.Addr
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.Contains(line, "/") {
+			addr, err := netip.ParseAddr(line)
+			if err != nil {
+				log.Printf("Native engine: skipping invalid entry %q: %v", line, err)
+				continue
+			}
+			ips = append(ips, addr)
+			continue
+		}
+		prefix, err := netip.ParsePrefix(line)
+		if err != nil {
+			log.Printf("Native engine: skipping invalid entry %q: %v", line, err)
+			continue
+		}
+		prefix = prefix.Masked()
+		if prefix.Addr().Is4() {
+			ips = append(ips, expandIPv4(prefix)...)
+		} else {
+			for i := 0; i < n.opts.IPv6Samples; i++ {
+				ips = append(ips, randomAddr(prefix))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ip file: %w", err)
+	}
+	return ips, nil
+}
+
+// expandIPv4 returns one random address from every /24 inside prefix.
+func expandIPv4(prefix netip.Prefix) []netip.Addr {
+	if prefix.Bits() >= 24 {
+		return []netip.Addr{randomAddr(prefix)}
+	}
+	var ips []netip.Addr
+	count := 1 << (24 - prefix.Bits())
+	base := prefix.Addr().As4()
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8
+	for i := 0; i < count; i++ {
+		v := start + uint32(i)<<8
+		sub := netip.PrefixFrom(netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), 0}), 24)
+		ips = append(ips, randomAddr(sub))
+	}
+	return ips
+}
+
+// randomAddr returns a random host address inside prefix.
+func randomAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	r := make([]byte, len(b))
+	_, _ = rand.Read(r)
+	bits := prefix.Bits()
+	for i := range b {
+		switch {
+		case bits >= 8:
+			bits -= 8
+		case bits > 0:
+			mask := byte(0xff >> bits)
+			b[i] = b[i]&^mask | r[i]&mask
+			bits = 0
+		default:
+			b[i] = r[i]
+		}
+	}
+	// 避免网络地址和广播地址
+	if last := len(b) - 1; prefix.Bits() <= last*8 && (b[last] == 0 || b[last] == 0xff) {
+		b[last] = 1 + r[last]%254
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// probeAll runs the TCP latency test for all IPs with bounded concurrency
+// and returns the IPs that answered within MaxLatencyMs.
+func (n *NativeTester) probeAll(ctx context.Context, ips []netip.Addr) []pingResult {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []pingResult
+	)
+	sem := make(chan struct{}, n.opts.Concurrency)
+	for _, ip := range ips {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ip netip.Addr) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			p := n.probe(ctx, ip)
+			if p.received == 0 || p.latency > time.Duration(n.opts.MaxLatencyMs)*time.Millisecond {
+				return
+			}
+			mu.Lock()
+			results = append(results, p)
+			mu.Unlock()
+		}(ip)
+	}
+	wg.Wait()
+	return results
+}
+
+// probe measures the average TCP connect time to ip and counts lost attempts.
+func (n *NativeTester) probe(ctx context.Context, ip netip.Addr) pingResult {
+	addr := netip.AddrPortFrom(ip, uint16(n.opts.Port)).String()
+	timeout := time.Duration(n.opts.TimeoutMs) * time.Millisecond
+	res := pingResult{ip: ip, sent: n.opts.PingTimes}
+	dialer := &net.Dialer{Timeout: timeout}
+	var total time.Duration
+	for i := 0; i < n.opts.PingTimes; i++ {
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			continue
+		}
+		total += time.Since(start)
+		res.received++
+		conn.Close()
+	}
+	if res.received > 0 {
+		res.latency = total / time.Duration(res.received)
+	}
+	return res
+}
+
+// download fetches the test URL through ip for DownloadTime seconds and
+// returns the average speed in MB/s together with the colo reported in the
+// CF-RAY response header.
+func (n *NativeTester) download(parent context.Context, ip netip.Addr) (float64, string, error) {
+	u, err := url.Parse(n.opts.URL)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid download url: %w", err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	target := net.JoinHostPort(ip.String(), port)
+	timeout := time.Duration(n.opts.TimeoutMs) * time.Millisecond
+	dialer := &net.Dialer{Timeout: timeout}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			// 所有请求都固定连接到被测 IP，Host 与 SNI 由请求地址决定
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, target)
+			},
+			// SNI 和证书校验由每个请求的 Host 决定，跳转到其他域名后仍然正确
+			TLSClientConfig:     &tls.Config{RootCAs: n.rootCAs},
+			TLSHandshakeTimeout: 2 * timeout,
+			DisableKeepAlives:   true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	duration := time.Duration(n.opts.DownloadTime) * time.Second
+	ctx, cancel := context.WithTimeout(parent, duration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("User-Agent", "cfst-client")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	colo := coloFromRay(resp.Header.Get("CF-RAY"))
+	if resp.StatusCode != http.StatusOK {
+		return 0, colo, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	written, err := io.Copy(io.Discard, resp.Body)
+	elapsed := time.Since(start)
+	if err != nil && ctx.Err() == nil {
+		return 0, colo, err
+	}
+	if elapsed <= 0 {
+		return 0, colo, nil
+	}
+	return float64(written) / elapsed.Seconds() / 1024 / 1024, colo, nil
+}
+
+// coloFromRay extracts the colo code from a CF-RAY header like "8a1b2c3d4e5f-SJC".
+func coloFromRay(ray string) string {
+	if i := strings.LastIndex(ray, "-"); i >= 0 {
+		return strings.ToUpper(ray[i+1:])
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package tester
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/config"
+)
+
+// newTestCert 生成一个覆盖 hosts 的自签名证书及对应的证书池
+func newTestCert(t *testing.T, hosts ...string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: hosts[0]},
+		DNSNames:              hosts,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestNativeDownloadFollowsCrossHostRedirect(t *testing.T) {
+	cert, pool := newTestCert(t, "start.test", "files.test")
+	payload := strings.Repeat("x", 256<<10)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, port, _ := net.SplitHostPort(r.Host)
+		// 模拟按 SNI 区分的虚拟主机：SNI 与 Host 不一致时拒绝请求
+		if r.TLS.ServerName != host {
+			http.Error(w, "misdirected request", http.StatusMisdirectedRequest)
+			return
+		}
+		switch {
+		case host == "start.test" && r.URL.Path == "/url":
+			http.Redirect(w, r, "https://files.test:"+port+"/file", http.StatusFound)
+		case host == "files.test" && r.URL.Path == "/file":
+			w.Header().Set("CF-RAY", "8a1b2c3d4e5f-SJC")
+			_, _ = w.Write([]byte(payload))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	n := NewNativeTester(config.NativeConfig{
+		URL:          "https://start.test:" + port + "/url",
+		DownloadTime: 5,
+	}, "", "dev", "op")
+	n.rootCAs = pool
+
+	speed, colo, err := n.download(context.Background(), netip.MustParseAddr("127.0.0.1"))
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if speed <= 0 {
+		t.Errorf("speed = %v, want > 0", speed)
+	}
+	if colo != "SJC" {
+		t.Errorf("colo = %q, want SJC", colo)
+	}
+}
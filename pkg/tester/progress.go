@@ -0,0 +1,154 @@
+package tester
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cfst-client/pkg/models"
+)
+
+// 进度事件的阶段
+const (
+	PhaseLatency  = "latency"  // 延迟测速，Done/Total 为已测试/总 IP 数
+	PhaseDownload = "download" // 下载测速，Done/Total 为已测试/计划测试的 IP 数
+	PhaseResult   = "result"   // 结果表中的单个 IP，Result 非空
+)
+
+// ProgressEvent 是从 CloudflareSpeedTest 输出中解析出的进度
+type ProgressEvent struct {
+	Phase     string
+	Done      int
+	Total     int
+	Available int                  // 延迟测速阶段已找到的可用 IP 数
+	Result    *models.DeviceResult // 仅 PhaseResult
+	Time      time.Time
+}
+
+// ProgressFunc 接收进度事件，在读取子进程输出的 goroutine 中同步调用，不应阻塞
+type ProgressFunc func(ProgressEvent)
+
+var (
+	ansiEscape        = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+	progressCounter   = regexp.MustCompile(`^(\d+)\s*/\s*(\d+)(?:\s|$)`)
+	progressAvailable = regexp.MustCompile(`(?i)(?:可用|available)\s*[:：]\s*(\d+)`)
+)
+
+// progressParser 是一个 io.Writer，按行（\r 或 \n）解析 CloudflareSpeedTest 的终端输出。
+// 进度条使用 \r 原地刷新，因此每次刷新都会被当作一行处理。
+type progressParser struct {
+	mu     sync.Mutex
+	fn     ProgressFunc
+	buf    []byte
+	phase  string
+	done   int
+	header bool // 已看到结果表的表头
+}
+
+func newProgressParser(fn ProgressFunc) *progressParser {
+	return &progressParser{fn: fn}
+}
+
+// Write 实现 io.Writer，始终返回 len(p)，解析失败不影响子进程
+func (p *progressParser) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		idx := bytes.IndexAny(p.buf, "\r\n")
+		if idx < 0 {
+			break
+		}
+		line := string(p.buf[:idx])
+		p.buf = p.buf[idx+1:]
+		p.parseLine(line)
+	}
+	return len(b), nil
+}
+
+// Flush 处理末尾没有换行的内容
+func (p *progressParser) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) > 0 {
+		p.parseLine(string(p.buf))
+		p.buf = nil
+	}
+}
+
+func (p *progressParser) parseLine(line string) {
+	line = strings.TrimSpace(ansiEscape.ReplaceAllString(line, ""))
+	if line == "" {
+		return
+	}
+	lower := strings.ToLower(line)
+
+	switch {
+	case strings.Contains(line, "延迟测速") || strings.Contains(lower, "latency test"):
+		p.phase, p.done = PhaseLatency, -1
+		return
+	case strings.Contains(line, "下载测速") || strings.Contains(lower, "download test"):
+		p.phase, p.done = PhaseDownload, -1
+		return
+	}
+
+	if m := progressCounter.FindStringSubmatch(line); m != nil && p.phase != "" {
+		done, _ := strconv.Atoi(m[1])
+		total, _ := strconv.Atoi(m[2])
+		if done == p.done {
+			return // 进度条重绘但数值未变化
+		}
+		p.done = done
+		ev := ProgressEvent{Phase: p.phase, Done: done, Total: total, Time: time.Now()}
+		if a := progressAvailable.FindStringSubmatch(line); a != nil {
+			ev.Available, _ = strconv.Atoi(a[1])
+		}
+		p.fn(ev)
+		return
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) > 0 && net.ParseIP(fields[0]) == nil {
+		// 结果表表头（如 "IP 地址 已发送 ..."）之后的行才作为结果解析
+		if strings.HasPrefix(lower, "ip") {
+			p.header = true
+		}
+		return
+	}
+	if p.header {
+		if res, ok := parseResultLine(fields); ok {
+			p.fn(ProgressEvent{Phase: PhaseResult, Result: &res, Time: time.Now()})
+		}
+	}
+}
+
+// parseResultLine 解析结果表中的一行：IP 已发送 已接收 丢包率 平均延迟 下载速度 [地区码]
+func parseResultLine(fields []string) (models.DeviceResult, bool) {
+	var res models.DeviceResult
+	if len(fields) < 6 {
+		return res, false
+	}
+	sent, err1 := strconv.Atoi(fields[1])
+	received, err2 := strconv.Atoi(fields[2])
+	loss, err3 := strconv.ParseFloat(strings.TrimSuffix(fields[3], "%"), 64)
+	latency, err4 := strconv.ParseFloat(fields[4], 64)
+	speed, err5 := strconv.ParseFloat(fields[5], 64)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil {
+		return res, false
+	}
+	res.IP = fields[0]
+	res.Sent = sent
+	res.Received = received
+	res.LossPct = loss
+	res.LatencyMs = int(latency)
+	res.AvgLatencyMs = latency
+	res.DLMBps = speed
+	if len(fields) > 6 {
+		res.Region = fields[6]
+	}
+	return res, true
+}
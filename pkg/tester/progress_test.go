@@ -0,0 +1,138 @@
+package tester
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cfst-client/pkg/models"
+)
+
+// cfstOutput 是 CloudflareSpeedTest v2.2 的终端输出片段：进度条以 \r 原地重绘并带有颜色码
+const cfstOutput = "# XIU2/CloudflareSpeedTest v2.2.5 \n\n" +
+	"开始延迟测速（模式：TCP, 端口：443, 范围：0 ~ 9999 ms, 丢包：1.00)\n" +
+	"\r 0 / 345 [\x1b[32m\x1b[0m______________________] 可用: 0" +
+	"\r 12 / 345 [\x1b[32m--->\x1b[0m__________________] 可用: 3" +
+	"\r 12 / 345 [\x1b[32m--->\x1b[0m__________________] 可用: 3" +
+	"\r\x1b[2K 345 / 345 [\x1b[32m---------------------\x1b[0m] 可用: 27\n" +
+	"开始下载测速（下限：0.00 MB/s, 数量：2, 队列：27）\n" +
+	"\r 1 / 2 [\x1b[32m---------->\x1b[0m__________]" +
+	"\r 2 / 2 [\x1b[32m---------------------\x1b[0m]\n\n" +
+	"\x1b[1mIP 地址           已发送  已接收  丢包率  平均延迟  下载速度 (MB/s)  地区码\x1b[0m\n" +
+	"104.16.1.1        4       4       0.00    120.52    12.34           HKG\n" +
+	"2606:4700::6810:1 4       3       0.25    130.00    8.50            LAX\n\n" +
+	"完整测速结果已写入 result.csv 文件，可使用记事本/表格软件查看。\n"
+
+func collectProgress(t *testing.T, chunks []string) []ProgressEvent {
+	t.Helper()
+	var events []ProgressEvent
+	p := newProgressParser(func(ev ProgressEvent) { events = append(events, ev) })
+	for _, c := range chunks {
+		if n, err := p.Write([]byte(c)); n != len(c) || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	p.Flush()
+	return events
+}
+
+// summarize 去掉时间戳，便于比较
+func summarize(events []ProgressEvent) []ProgressEvent {
+	out := make([]ProgressEvent, len(events))
+	for i, ev := range events {
+		ev.Time = time.Time{}
+		out[i] = ev
+	}
+	return out
+}
+
+func TestProgressParserCfstOutput(t *testing.T) {
+	want := []ProgressEvent{
+		{Phase: PhaseLatency, Done: 0, Total: 345},
+		{Phase: PhaseLatency, Done: 12, Total: 345, Available: 3},
+		{Phase: PhaseLatency, Done: 345, Total: 345, Available: 27},
+		{Phase: PhaseDownload, Done: 1, Total: 2},
+		{Phase: PhaseDownload, Done: 2, Total: 2},
+		{Phase: PhaseResult, Result: &models.DeviceResult{IP: "104.16.1.1", Sent: 4, Received: 4,
+			LatencyMs: 120, AvgLatencyMs: 120.52, DLMBps: 12.34, Region: "HKG"}},
+		{Phase: PhaseResult, Result: &models.DeviceResult{IP: "2606:4700::6810:1", Sent: 4, Received: 3,
+			LossPct: 0.25, LatencyMs: 130, AvgLatencyMs: 130, DLMBps: 8.5, Region: "LAX"}},
+	}
+
+	// 整段写入和逐字节写入（模拟管道中任意切分的输出）结果一致
+	whole := collectProgress(t, []string{cfstOutput})
+	var bytewise []string
+	for i := 0; i < len(cfstOutput); i++ {
+		bytewise = append(bytewise, cfstOutput[i:i+1])
+	}
+	split := collectProgress(t, bytewise)
+
+	for name, got := range map[string][]ProgressEvent{"whole": whole, "bytewise": split} {
+		if got := summarize(got); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: events =\n%s\nwant\n%s", name, formatEvents(got), formatEvents(want))
+		}
+	}
+	for _, ev := range whole {
+		if ev.Time.IsZero() {
+			t.Errorf("event %+v has no time", ev)
+		}
+	}
+}
+
+func TestProgressParserEnglishOutputAndOldResultTable(t *testing.T) {
+	out := "Start latency test (Mode: TCP, Port: 443)\r\n" +
+		"\r 5 / 10 [====>_____] Available: 2\r\n" +
+		"Start download test (Min: 0.00 MB/s, Num: 1)\r\n" +
+		"\r 1 / 1 [==========]\r\n" +
+		"IP Address  Sent  Received  Loss  Avg Latency  Download Speed (MB/s)\r\n" +
+		"1.0.0.1     4     4         0.00  99.00        3.20" // 旧版本没有地区码，且没有结尾换行
+	got := summarize(collectProgress(t, []string{out}))
+	want := []ProgressEvent{
+		{Phase: PhaseLatency, Done: 5, Total: 10, Available: 2},
+		{Phase: PhaseDownload, Done: 1, Total: 1},
+		{Phase: PhaseResult, Result: &models.DeviceResult{IP: "1.0.0.1", Sent: 4, Received: 4,
+			LatencyMs: 99, AvgLatencyMs: 99, DLMBps: 3.2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events =\n%s\nwant\n%s", formatEvents(got), formatEvents(want))
+	}
+}
+
+func TestProgressParserIgnoresCountersBeforePhaseAndRowsBeforeHeader(t *testing.T) {
+	out := "3 / 7 stray counter\n" +
+		"104.16.1.1 4 4 0.00 120.52 12.34 HKG\n"
+	if got := collectProgress(t, []string{out}); len(got) != 0 {
+		t.Errorf("events = %s, want none", formatEvents(got))
+	}
+}
+
+func TestParseResultLine(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{"104.16.1.1 4 4 0.00 120.52 12.34 HKG", true},
+		{"104.16.1.1 4 4 25% 120.52 12.34", true},
+		{"104.16.1.1 4 4 0.00 120.52", false},
+		{"104.16.1.1 4 x 0.00 120.52 12.34", false},
+	}
+	for _, tt := range tests {
+		if _, ok := parseResultLine(strings.Fields(tt.line)); ok != tt.ok {
+			t.Errorf("parseResultLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+		}
+	}
+}
+
+func formatEvents(events []ProgressEvent) string {
+	var sb strings.Builder
+	for _, ev := range events {
+		fmt.Fprintf(&sb, "  %s %d/%d available=%d", ev.Phase, ev.Done, ev.Total, ev.Available)
+		if ev.Result != nil {
+			fmt.Fprintf(&sb, " %+v", *ev.Result)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,25 @@
+package tester
+
+import (
+	"context"
+	"errors"
+
+	"cfst-client/pkg/models"
+)
+
+// ErrExec is returned (wrapped) when the external CloudflareSpeedTest binary
+// could not be started or was terminated by a signal, as opposed to exiting
+// with a non-zero status or producing no results.
+var ErrExec = errors.New("command execution failed")
+
+// Tester is implemented by every speed-test engine.
+type Tester interface {
+	// Run performs one full speed test and returns the parsed results.
+	// The test is aborted when ctx is cancelled or its deadline expires.
+	Run(ctx context.Context) ([]models.DeviceResult, error)
+}
+
+var (
+	_ Tester = (*CFSpeedTester)(nil)
+	_ Tester = (*NativeTester)(nil)
+)